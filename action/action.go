@@ -1,21 +1,26 @@
 package action
 
 import (
+	"context"
 	"log"
-	"time"
 
 	"github.com/damianoneill/nc-hammer/result"
 	"github.com/damianoneill/nc-hammer/suite"
 )
 
-// Execute used to determine type of Action and call the appropriate function
-func Execute(tsStart time.Time, cID int, ts *suite.TestSuite, action suite.Action, resultChannel chan result.NetconfResult) {
+// Execute used to determine type of Action and call the appropriate function, a subscription or a sleep ends early
+// when the context is done
+func Execute(ctx context.Context, client *Client, ts *suite.TestSuite, action suite.Action, resultChannel chan result.NetconfResult) {
 	switch {
 	case action.Netconf != nil:
-		ExecuteNetconf(tsStart, cID, action, ts.GetConfig(action.Netconf.Hostname), resultChannel)
+		ExecuteNetconf(client, action, ts.GetConfig(action.Netconf.Hostname), resultChannel)
+	case action.Subscribe != nil:
+		ExecuteSubscribe(ctx, client, action, ts.GetConfig(action.Subscribe.Hostname), resultChannel)
+	case action.YangPush != nil:
+		ExecuteYangPush(client, action, ts.GetConfig(action.YangPush.Hostname), resultChannel)
 	case action.Sleep != nil:
-		ExecuteSleep(action)
+		ExecuteSleep(ctx, client, action)
 	default:
-		log.Printf("\n ** Problem with your Testsuite, an action in a block section has incorrect YAML indentation for its body, ensure that anything after netconf or sleep is properly indented **\n\n")
+		log.Printf("\n ** Problem with your Testsuite, an action in a block section has incorrect YAML indentation for its body, ensure that anything after netconf, subscribe, yang-push or sleep is properly indented **\n\n")
 	}
 }
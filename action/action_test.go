@@ -2,6 +2,7 @@ package action
 
 import (
 	"bytes"
+	"context"
 	"log"
 	"testing"
 	"time"
@@ -26,10 +27,10 @@ func Test_Execute(t *testing.T) {
 				log.SetFlags(log.Flags() &^ (log.Ldate | log.Ltime))
 				log.SetOutput(&buff)
 				if testsuite == tsValid {
-					Execute(start, 0, tsValid, a, resultChannel)
+					Execute(context.Background(), NewClient(0, start), tsValid, a, resultChannel)
 					assert.True(t, (a.Sleep != nil) || (a.Netconf != nil)) // checks for netconf or sleep actions
 				} else {
-					Execute(start, 0, tsInvalid, a, resultChannel)
+					Execute(context.Background(), NewClient(0, start), tsInvalid, a, resultChannel)
 					got := buff.String()
 					want := "Problem"
 					assert.Contains(t, got, want)
@@ -39,3 +40,16 @@ func Test_Execute(t *testing.T) {
 		}
 	}
 }
+
+func Test_ExecuteSleepContextDone(t *testing.T) {
+	a := suite.Action{Sleep: &suite.Sleep{Duration: 60000}}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	ExecuteSleep(ctx, NewClient(1, start), a)
+	assert.True(t, time.Since(start) < time.Second, "the sleep ends when the context is done")
+
+	start = time.Now()
+	ExecuteSleep(context.Background(), NewClient(1, start), suite.Action{Sleep: &suite.Sleep{Duration: 20}})
+	assert.True(t, time.Since(start) >= 20*time.Millisecond, "the sleep lasts its period")
+}
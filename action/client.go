@@ -0,0 +1,150 @@
+package action
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/result"
+	"github.com/damianoneill/nc-hammer/suite"
+)
+
+// Client holds the state of a client executing actions, the results of its actions are stamped from it
+type Client struct {
+	ID         int
+	Population string        // the name of the population the client belongs to, if any
+	Start      time.Time     // when the Test Suite started, result timings are relative to this
+	Lag        time.Duration // how late the current iteration started compared to when it was scheduled
+	Stage      *int32        // the active load profile stage, nil when the suite does not define one
+	Phase      string        // the setup or teardown phase the client is executing, empty while generating load
+	Misses     *int64        // counts the iterations that overran their pacing, shared by the clients of a run
+	Iteration  int           // the number of iterations the client has started
+	Warmup     bool          // true while the current iteration is part of the warm-up
+	PacingMiss bool          // true while the current iteration started late, as the previous one overran its pacing
+	Seed       int64         // the suite's random seed, used by the blocks and sleeps that do not set their own
+
+	// the counters of the templates the client expands, shared by the clients of a run
+	Counters *suite.Counters
+
+	randomLock sync.Mutex
+	randoms    map[interface{}]*rand.Rand
+	replyLock  sync.Mutex
+	reply      string
+	pushLock   sync.Mutex
+	push       map[string]*pushSession // the sessions holding the client's YANG-push subscriptions, keyed by host
+
+	pipelineLock sync.Mutex
+	pipelines    map[string]*pipeline // the windows of pipelined requests, keyed by host and depth
+}
+
+// NewClient returns a Client for the client id, whose result timings are relative to the Test Suite start
+func NewClient(cID int, tsStart time.Time) *Client {
+	return &Client{ID: cID, Start: tsStart, randoms: make(map[interface{}]*rand.Rand), push: make(map[string]*pushSession), pipelines: make(map[string]*pipeline), Counters: suite.NewCounters()}
+}
+
+// Random returns the source of random numbers the client uses for key (for e.g. a block). The source is seeded from
+// seed mixed with the client id, so that each client makes a different but reproducible sequence of choices. When seed is
+// zero the client's own source is returned instead, seeded in the same way from the client's Seed, or from the time
+// when that is zero as well. The source is safe for use by concurrent goroutines.
+func (c *Client) Random(key interface{}, seed int64) *rand.Rand {
+	if seed == 0 {
+		key, seed = c, c.Seed
+	}
+	c.randomLock.Lock()
+	defer c.randomLock.Unlock()
+	r, present := c.randoms[key]
+	if !present {
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		r = rand.New(&lockedSource{src: rand.NewSource(clientSeed(seed, c.ID))}) // #nosec
+		c.randoms[key] = r
+	}
+	return r
+}
+
+// clientSeed mixes the seed with the client id, as splitmix64 does, so that the sources of neighbouring clients, or of
+// the same client in runs with neighbouring seeds, are unrelated rather than offset by one
+func clientSeed(seed int64, id int) int64 {
+	z := uint64(seed) + uint64(id+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
+
+// Vars returns the variables the templates of an action sent to the host are expanded with, the random values are
+// drawn from the client's own source
+func (c *Client) Vars(hostname string) *suite.Vars {
+	return suite.NewVars(c.ID, c.Iteration, hostname, c.Population, c.Counters, c.Random(nil, 0))
+}
+
+// newResult returns the result of a request the client sends to the host, stamped with the client's population, stage,
+// phase and iteration. The client's last reply is cleared, as a failed request leaves no reply.
+func (c *Client) newResult(hostname, operation string) result.NetconfResult {
+	c.setReply("")
+	return result.NetconfResult{
+		Client:     c.ID,
+		Hostname:   hostname,
+		Operation:  operation,
+		Population: c.Population,
+		Stage:      c.stage(),
+		Phase:      c.Phase,
+		Warmup:     c.Warmup && c.Phase == "",
+		Iteration:  c.Iteration,
+		PacingMiss: c.PacingMiss && c.Phase == "",
+	}
+}
+
+// LastReply returns the data of the last reply the client received, empty if the last request failed
+func (c *Client) LastReply() string {
+	c.replyLock.Lock()
+	defer c.replyLock.Unlock()
+	return c.reply
+}
+
+func (c *Client) setReply(data string) {
+	c.replyLock.Lock()
+	defer c.replyLock.Unlock()
+	c.reply = data
+}
+
+// lockedSource guards a rand.Source so that it can be shared by concurrent goroutines
+type lockedSource struct {
+	lock sync.Mutex
+	src  rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.src.Seed(seed)
+}
+
+// MissedPacing records that the previous iteration overran its pacing interval, the results of the current iteration
+// are marked as a pacing miss
+func (c *Client) MissedPacing() {
+	c.PacingMiss = true
+	if c.Misses != nil {
+		atomic.AddInt64(c.Misses, 1)
+	}
+}
+
+// stage returns the load profile stage the client is currently executing, 0 if there is none
+func (c *Client) stage() int {
+	if c.Stage == nil {
+		return 0
+	}
+	return int(atomic.LoadInt32(c.Stage))
+}
+
+// sinceStart returns the milliseconds elapsed between the Test Suite start and t
+func (c *Client) sinceStart(t time.Time) float64 {
+	return float64(t.Sub(c.Start).Nanoseconds() / int64(time.Millisecond))
+}
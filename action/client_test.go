@@ -0,0 +1,52 @@
+package action
+
+import (
+	"testing"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/result"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Random(t *testing.T) {
+	start := time.Now()
+	c1, c2 := NewClient(1, start), NewClient(1, start)
+	key := "block"
+	// the same seed and client id give the same sequence, the source is kept per key
+	assert.Equal(t, c1.Random(key, 42).Int63(), c2.Random(key, 42).Int63())
+	assert.Equal(t, c1.Random(key, 42).Int63(), c2.Random(key, 42).Int63())
+	assert.True(t, c1.Random(key, 42) == c1.Random(key, 42))
+	assert.False(t, c1.Random(key, 42) == c1.Random("other", 42))
+
+	// clients with a different id make different choices
+	assert.NotEqual(t, NewClient(1, start).Random(key, 42).Int63(), NewClient(2, start).Random(key, 42).Int63())
+	// as do neighbouring clients in runs with neighbouring seeds
+	assert.NotEqual(t, NewClient(1, start).Random(key, 42).Int63(), NewClient(0, start).Random(key, 43).Int63())
+}
+
+func TestClient_RandomSeed(t *testing.T) {
+	start := time.Now()
+	c1, c2 := NewClient(1, start), NewClient(1, start)
+	c1.Seed, c2.Seed = 7, 7
+	// without a seed of its own, a key uses the client's source seeded from the suite seed
+	assert.True(t, c1.Random("block", 0) == c1.Random("sleep", 0))
+	assert.Equal(t, c1.Random("block", 0).Int63(), c2.Random("block", 0).Int63())
+	assert.False(t, c1.Random("block", 0) == c1.Random("block", 42), "a seed of its own takes precedence")
+}
+
+func TestClient_newResult(t *testing.T) {
+	c := NewClient(3, time.Now())
+	c.Population, c.Iteration, c.Warmup, c.PacingMiss = "monitoring", 2, true, true
+	c.setReply("<ok/>")
+
+	r := c.newResult("10.0.0.1", "get")
+	assert.Equal(t, result.NetconfResult{Client: 3, Hostname: "10.0.0.1", Operation: "get", Population: "monitoring", Warmup: true, Iteration: 2, PacingMiss: true}, r)
+	assert.Equal(t, "", c.LastReply(), "a failed request leaves no reply")
+
+	// the setup and teardown phases are neither warm-up nor paced
+	c.Phase = "client-setup"
+	r = c.newResult("10.0.0.1", "get")
+	assert.Equal(t, "client-setup", r.Phase)
+	assert.False(t, r.Warmup)
+	assert.False(t, r.PacingMiss)
+}
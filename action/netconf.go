@@ -3,8 +3,10 @@ package action
 import (
 	"context"
 	"fmt"
+	"log"
 	"regexp"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/damianoneill/nc-hammer/result"
@@ -15,6 +17,7 @@ import (
 
 var (
 	diagnosticContext = context.Background()
+	diagnostics       bool // true when netconf client diagnostics are enabled
 )
 
 // CreateDiagnosticContext creates a context used for instantiating new Netconf sessions, with the option
@@ -27,20 +30,28 @@ func CreateDiagnosticContext(diagFlag bool) {
 		trace = netconf.DefaultLoggingHooks
 	}
 	diagnosticContext = netconf.WithClientTrace(diagnosticContext, trace)
+	diagnostics = diagFlag
 }
 
-var gSessions map[string]netconf.Session
+var (
+	gSessions    map[string]netconf.Session
+	sessionsLock sync.Mutex // guards gSessions, clients share the cache
+)
 
 func init() {
 	gSessions = make(map[string]netconf.Session)
 }
 
-// CloseAllSessions is called on exit to gracefully close the sockets
+// CloseAllSessions is called on exit to gracefully close the sockets, the cache is emptied so that a later run
+// (for e.g. a worker's next job) establishes new sessions
 func CloseAllSessions() {
+	sessionsLock.Lock()
+	defer sessionsLock.Unlock()
 	// nolint
 	for _, session := range gSessions {
 		session.Close()
 	}
+	gSessions = make(map[string]netconf.Session)
 }
 
 func operationOrMessage(netconf *suite.Netconf) string {
@@ -51,23 +62,41 @@ func operationOrMessage(netconf *suite.Netconf) string {
 }
 
 // ExecuteNetconf invoked when a NETCONF Action is identified
-func ExecuteNetconf(tsStart time.Time, cID int, action suite.Action, config *suite.Sshconfig, resultChannel chan result.NetconfResult) {
+func ExecuteNetconf(client *Client, action suite.Action, config *suite.Sshconfig, resultChannel chan result.NetconfResult) {
 
-	var result result.NetconfResult
-	result.Client = cID
-	result.Hostname = action.Netconf.Hostname
-	result.Operation = operationOrMessage(action.Netconf)
+	result := client.newResult(action.Netconf.Hostname, operationOrMessage(action.Netconf))
+	if action.Netconf.Datastore != nil {
+		result.Datastore = *action.Netconf.Datastore
+	}
 
-	session, err := getSession(cID, config.Hostname+":"+strconv.Itoa(config.Port), config.Username, config.Password, config.Reuseconnection)
+	// the templates are expanded each time the action is executed
+	vars := client.Vars(action.Netconf.Hostname)
+	expanded, err := action.Netconf.Expand(vars)
 	if err != nil {
 		fmt.Printf("E")
 		result.Err = err.Error()
 		resultChannel <- result
 		return
 	}
+	if diagnostics && len(vars.Rendered()) > 0 {
+		log.Printf("TemplateRendered client:%d iteration:%d host:%s values:%v\n", vars.Client, vars.Iteration, vars.Host, vars.Rendered())
+	}
+	action.Netconf = expanded
+
+	// pipelined requests share the client's session to the host
+	pipeline := action.Netconf.GetPipeline(config)
+	reuse := config.Reuseconnection || pipeline > 0
+	session, err := getSession(client.ID, config.Hostname+":"+strconv.Itoa(config.Port), config.Username, config.Password, reuse)
+	if err != nil {
+		fmt.Printf("E")
+		result.Err = err.Error()
+		result.ConnectionFailure = true
+		resultChannel <- result
+		return
+	}
 
 	// not reusing the connection, then explicitly close it
-	if !config.Reuseconnection {
+	if !reuse {
 		// nolint
 		defer session.Close()
 	}
@@ -77,6 +106,7 @@ func ExecuteNetconf(tsStart time.Time, cID int, action suite.Action, config *sui
 	} else {
 		fmt.Printf("E")
 		result.Err = "session has expired"
+		result.ConnectionFailure = true
 		resultChannel <- result
 		return
 	}
@@ -90,22 +120,44 @@ func ExecuteNetconf(tsStart time.Time, cID int, action suite.Action, config *sui
 	}
 
 	raw := netconf.Request(xml)
+	if pipeline > 0 {
+		executePipelined(client, action.Netconf, config, session, raw, pipeline, result, resultChannel)
+		return
+	}
+	// wait for a slot when the host caps the requests in flight, the wait counts as a late start
+	ready := time.Now()
+	slots := hostSlots(config)
+	if slots != nil {
+		slots <- struct{}{}
+	}
 	start := time.Now()
+	// the intended start is offset by how late the iteration started, to allow for coordinated omission correction
+	result.Started = client.sinceStart(start)
+	result.Intended = client.sinceStart(ready.Add(-client.Lag))
 	rpcReply, err := session.Execute(raw)
+	if slots != nil {
+		<-slots
+	}
 	if err != nil {
 		result.Err = err.Error()
 		fmt.Printf("e")
 		resultChannel <- result
 		return
 	}
+	recordReply(client, action.Netconf, result, start, rpcReply, resultChannel)
+}
+
+// recordReply records the result of a request started at start, once its reply has been received
+func recordReply(client *Client, n *suite.Netconf, result result.NetconfResult, start time.Time, rpcReply *netconf.RPCReply, resultChannel chan result.NetconfResult) {
 	elapsed := time.Since(start)
-	result.When = float64(time.Since(tsStart).Nanoseconds() / int64(time.Millisecond))
+	result.When = client.sinceStart(time.Now())
 	result.Latency = float64(elapsed.Nanoseconds() / int64(time.Millisecond))
 
 	result.MessageID = rpcReply.MessageID
+	client.setReply(rpcReply.Data)
 
-	if action.Netconf.Expected != nil {
-		match, err := regexp.MatchString(*action.Netconf.Expected, rpcReply.Data)
+	if n.Expected != nil {
+		match, err := regexp.MatchString(*n.Expected, rpcReply.Data)
 		if err != nil {
 			fmt.Printf("E")
 			result.Err = err.Error()
@@ -114,7 +166,7 @@ func ExecuteNetconf(tsStart time.Time, cID int, action suite.Action, config *sui
 		}
 		if !match {
 			fmt.Printf("e")
-			result.Err = "expected response did not match, expected: " + *action.Netconf.Expected + " actual: " + rpcReply.Data
+			result.Err = "expected response did not match, expected: " + *n.Expected + " actual: " + rpcReply.Data
 			resultChannel <- result
 			return
 		}
@@ -122,19 +174,50 @@ func ExecuteNetconf(tsStart time.Time, cID int, action suite.Action, config *sui
 	resultChannel <- result
 }
 
+var (
+	gHostSlots    = make(map[string]chan struct{})
+	hostSlotsLock sync.Mutex // guards gHostSlots
+)
+
+// hostSlots returns the slots that cap the requests in flight to a host, shared by all clients, nil if uncapped
+func hostSlots(config *suite.Sshconfig) chan struct{} {
+	if config.Maxoutstanding <= 0 {
+		return nil
+	}
+	hostSlotsLock.Lock()
+	defer hostSlotsLock.Unlock()
+	slots, present := gHostSlots[config.Hostname]
+	if !present || cap(slots) != config.Maxoutstanding {
+		slots = make(chan struct{}, config.Maxoutstanding)
+		gHostSlots[config.Hostname] = slots
+	}
+	return slots
+}
+
 // getSession returns a NETCONF Session, either a new one or a pre existing one if resuseConnection is valid for client/host
 func getSession(client int, hostname, username, password string, reuseConnection bool) (netconf.Session, error) {
 	// check if hostname should reuse connection
 	if reuseConnection {
+		key := strconv.Itoa(client) + hostname
 		// get Session from Map if present
-		session, present := gSessions[strconv.Itoa(client)+hostname]
+		sessionsLock.Lock()
+		session, present := gSessions[key]
+		sessionsLock.Unlock()
 		if present {
 			return session, nil
 		}
 		// not present in map, therefore first time its called, create a new session and store in map
 		session, err := createNewSession(hostname, username, password)
 		if err == nil {
-			gSessions[strconv.Itoa(client)+hostname] = session
+			sessionsLock.Lock()
+			defer sessionsLock.Unlock()
+			// a concurrent action of the client may have stored a session in the meantime
+			if existing, present := gSessions[key]; present && session != nil {
+				// nolint
+				session.Close()
+				return existing, nil
+			}
+			gSessions[key] = session
 		}
 		return session, err
 	}
@@ -4,8 +4,11 @@ import (
 	"encoding/xml"
 	"errors"
 	"io/ioutil"
+	"log"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -32,7 +35,7 @@ func captureStdoutE(sessionID int) string {
 	rescueStdout := os.Stdout
 	r, w, _ := os.Pipe()
 	os.Stdout = w
-	ExecuteNetconf(start, sessionID, myAction, myConfig, resultChannel)
+	ExecuteNetconf(NewClient(sessionID, start), myAction, myConfig, resultChannel)
 	time.Sleep(500 * time.Millisecond)
 	w.Close()
 	out, _ := ioutil.ReadAll(r)
@@ -109,3 +112,194 @@ func Test_NetconfDiagnosticContext(t *testing.T) {
 	assert.Equal(t, netconf.DefaultLoggingHooks, netconf.ContextClientTrace(nonDiagContext), "Expect context not to enable diagnostics")
 	assert.Equal(t, netconf.DiagnosticLoggingHooks, netconf.ContextClientTrace(diagContext), "Expect context to enable diagnostics")
 }
+
+func Test_hostSlots(t *testing.T) {
+	assert.Nil(t, hostSlots(&suite.Sshconfig{Hostname: "10.0.0.9"}))
+	slots := hostSlots(&suite.Sshconfig{Hostname: "10.0.0.9", Maxoutstanding: 2})
+	assert.Equal(t, 2, cap(slots))
+	assert.True(t, slots == hostSlots(&suite.Sshconfig{Hostname: "10.0.0.9", Maxoutstanding: 2}), "slots are shared by all clients")
+	assert.Equal(t, 3, cap(hostSlots(&suite.Sshconfig{Hostname: "10.0.0.9", Maxoutstanding: 3})))
+}
+
+func Test_ExecuteNetconfMaxoutstanding(t *testing.T) {
+	var inFlight, maxInFlight int32
+	mockSession := &mocks.Session{}
+	mockSession.On("ID").Return(75)
+	mockSession.On("Close").Return()
+	mockSession.On("Execute", mock.Anything).Return(&netconf.RPCReply{}, nil).Run(func(mock.Arguments) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	})
+	rescueCreateNewSession := createNewSession
+	defer func() { createNewSession = rescueCreateNewSession }()
+	createNewSession = func(hostname, username, password string) (netconf.Session, error) {
+		return mockSession, nil
+	}
+
+	operation := "get"
+	a := suite.Action{Netconf: &suite.Netconf{Hostname: "10.0.0.8", Operation: &operation}}
+	config := &suite.Sshconfig{Hostname: "10.0.0.8", Port: 830, Maxoutstanding: 2}
+	resultChannel := make(chan result.NetconfResult, 6)
+	rescueStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+	var wg sync.WaitGroup
+	start := time.Now()
+	for cID := 0; cID < 6; cID++ {
+		wg.Add(1)
+		go func(cID int) {
+			defer wg.Done()
+			ExecuteNetconf(NewClient(cID, start), a, config, resultChannel)
+		}(cID)
+	}
+	wg.Wait()
+	w.Close()
+	os.Stdout = rescueStdout
+	close(resultChannel)
+
+	// the requests queue for the 2 slots, the wait is recorded as a late start
+	assert.Equal(t, int32(2), maxInFlight)
+	var late int
+	for r := range resultChannel {
+		assert.Equal(t, "", r.Err)
+		if r.Started > r.Intended {
+			late++
+		}
+	}
+	assert.True(t, late >= 2, "queued requests should start late")
+}
+
+func Test_ExecuteNetconfPipelined(t *testing.T) {
+	sent := make(chan chan *netconf.RPCReply, 3)
+	mockSession := &mocks.Session{}
+	mockSession.On("ID").Return(76)
+	mockSession.On("Close").Return()
+	mockSession.On("ExecuteAsync", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		sent <- args.Get(1).(chan *netconf.RPCReply)
+	})
+	rescueCreateNewSession := createNewSession
+	defer func() { createNewSession = rescueCreateNewSession }()
+	createNewSession = func(hostname, username, password string) (netconf.Session, error) {
+		return mockSession, nil
+	}
+	defer func() {
+		sessionsLock.Lock()
+		delete(gSessions, "110.0.0.7:830")
+		sessionsLock.Unlock()
+	}()
+
+	operation := "get"
+	a := suite.Action{Netconf: &suite.Netconf{Hostname: "10.0.0.7", Operation: &operation, Pipeline: 2}}
+	// the host does not reuse its sessions, pipelined requests share the client's session regardless
+	config := &suite.Sshconfig{Hostname: "10.0.0.7", Port: 830}
+	client := NewClient(1, time.Now())
+	resultChannel := make(chan result.NetconfResult, 3)
+	rescueStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			ExecuteNetconf(client, a, config, resultChannel)
+		}
+		close(done)
+	}()
+
+	// the third request waits for a slot in the window
+	first, second := <-sent, <-sent
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, sent, 0)
+	// results are recorded as the replies are received, for each message-id
+	second <- &netconf.RPCReply{MessageID: "m-2", Data: "<two/>"}
+	r := <-resultChannel
+	assert.Equal(t, "m-2", r.MessageID)
+	assert.Equal(t, "", r.Err)
+	assert.Equal(t, 2, r.Pipeline)
+	assert.Equal(t, 76, r.SessionID)
+	assert.Equal(t, "<two/>", client.LastReply())
+	third := <-sent
+	<-done
+	first <- &netconf.RPCReply{MessageID: "m-1", Errors: []netconf.RPCError{{Severity: "error", Message: "in use"}}}
+	r = <-resultChannel
+	assert.Equal(t, "netconf rpc [error] 'in use'", r.Err)
+	close(third)
+	EndPipelines(client)
+	w.Close()
+	os.Stdout = rescueStdout
+	r = <-resultChannel
+	assert.Equal(t, "unexpected EOF", r.Err, "the session closed before the reply")
+	assert.Len(t, resultChannel, 0)
+	mockSession.AssertNotCalled(t, "Execute", mock.Anything)
+}
+
+func Test_ExecuteNetconfTemplates(t *testing.T) {
+	var requests []string
+	mockSession := &mocks.Session{}
+	mockSession.On("ID").Return(77)
+	mockSession.On("Close").Return()
+	mockSession.On("Execute", mock.Anything).Return(&netconf.RPCReply{Data: "<ok/>"}, nil).Run(func(args mock.Arguments) {
+		requests = append(requests, string(args.Get(0).(netconf.Request)))
+	})
+	rescueCreateNewSession := createNewSession
+	defer func() { createNewSession = rescueCreateNewSession }()
+	createNewSession = func(hostname, username, password string) (netconf.Session, error) {
+		return mockSession, nil
+	}
+	rescueDiagnostics := diagnostics
+	defer func() { diagnostics = rescueDiagnostics }()
+	diagnostics = true
+	var logged strings.Builder
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	message, method := "rpc", `<get-schema><identifier>{{.Host}}-{{.Client}}-{{.Iteration}}-{{.Counter "schemas"}}</identifier></get-schema>`
+	a := suite.Action{Netconf: &suite.Netconf{Hostname: "10.0.0.6", Message: &message, Method: &method}}
+	config := &suite.Sshconfig{Hostname: "10.0.0.6", Port: 830}
+	counters := suite.NewCounters()
+	resultChannel := make(chan result.NetconfResult, 2)
+	for cID := 1; cID <= 2; cID++ {
+		client := NewClient(cID, time.Now())
+		client.Iteration = 5
+		client.Counters = counters
+		ExecuteNetconf(client, a, config, resultChannel)
+		assert.Equal(t, "", (<-resultChannel).Err)
+	}
+
+	// each execution sends its own rendering of the templates, the action is left as is
+	assert.Equal(t, []string{"<get-schema><identifier>10.0.0.6-1-5-1</identifier></get-schema>", "<get-schema><identifier>10.0.0.6-2-5-2</identifier></get-schema>"}, requests)
+	assert.Equal(t, `<get-schema><identifier>{{.Host}}-{{.Client}}-{{.Iteration}}-{{.Counter "schemas"}}</identifier></get-schema>`, *a.Netconf.Method)
+	assert.Contains(t, logged.String(), `TemplateRendered client:2 iteration:5 host:10.0.0.6 values:[Counter "schemas"=2]`)
+}
+
+func Test_ExecuteNetconfConnectionFailure(t *testing.T) {
+	rescueCreateNewSession := createNewSession
+	defer func() { createNewSession = rescueCreateNewSession }()
+	createNewSession = func(hostname, username, password string) (netconf.Session, error) {
+		return nil, errors.New("connection refused")
+	}
+	rescueStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+	defer func() { os.Stdout = rescueStdout }()
+
+	operation, expected := "get", "{{.RandInt 9 1}}"
+	config := &suite.Sshconfig{Hostname: "10.0.0.5", Port: 830}
+	resultChannel := make(chan result.NetconfResult, 2)
+	ExecuteNetconf(NewClient(1, time.Now()), suite.Action{Netconf: &suite.Netconf{Hostname: "10.0.0.5", Operation: &operation}}, config, resultChannel)
+	r := <-resultChannel
+	assert.Equal(t, "connection refused", r.Err)
+	assert.True(t, r.IsConnectionFailure())
+
+	// the templates are expanded before a session is needed
+	ExecuteNetconf(NewClient(1, time.Now()), suite.Action{Netconf: &suite.Netconf{Hostname: "10.0.0.5", Operation: &operation, Expected: &expected}}, config, resultChannel)
+	r = <-resultChannel
+	assert.Contains(t, r.Err, "RandInt max cannot be less than min")
+	assert.False(t, r.IsConnectionFailure())
+}
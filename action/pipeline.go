@@ -0,0 +1,103 @@
+package action
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/result"
+	"github.com/damianoneill/nc-hammer/suite"
+	"github.com/damianoneill/net/netconf"
+)
+
+// pipeline is the window of requests a client keeps in flight on its session to a host, an action waits for a slot
+// in the window rather than for the reply to its request
+type pipeline struct {
+	slots    chan struct{}
+	inflight sync.WaitGroup // the requests awaiting their reply
+}
+
+// pipeline returns the client's window of depth requests to the host, the actions with the same depth share a window
+func (c *Client) pipeline(hostname string, depth int) *pipeline {
+	c.pipelineLock.Lock()
+	defer c.pipelineLock.Unlock()
+	key := hostname + "/" + strconv.Itoa(depth)
+	p, present := c.pipelines[key]
+	if !present {
+		p = &pipeline{slots: make(chan struct{}, depth)}
+		c.pipelines[key] = p
+	}
+	return p
+}
+
+// executePipelined sends the request on the session, once there is a slot for it in the client's window, and returns
+// without waiting for the reply. The result is recorded when the reply is received, with the latency of the request's
+// message-id measured from when it was sent.
+func executePipelined(client *Client, n *suite.Netconf, config *suite.Sshconfig, session netconf.Session, req netconf.Request, depth int, result result.NetconfResult, resultChannel chan result.NetconfResult) {
+	result.Pipeline = depth
+	p := client.pipeline(n.Hostname, depth)
+	// the wait for a slot in the window, or for one of the host's, counts as a late start
+	ready := time.Now()
+	p.slots <- struct{}{}
+	slots := hostSlots(config)
+	if slots != nil {
+		slots <- struct{}{}
+	}
+	release := func() {
+		if slots != nil {
+			<-slots
+		}
+		<-p.slots
+	}
+
+	replies := make(chan *netconf.RPCReply, 1)
+	start := time.Now()
+	result.Started = client.sinceStart(start)
+	result.Intended = client.sinceStart(ready.Add(-client.Lag))
+	if err := session.ExecuteAsync(req, replies); err != nil {
+		release()
+		result.Err = err.Error()
+		fmt.Printf("e")
+		resultChannel <- result
+		return
+	}
+	p.inflight.Add(1)
+	go func() {
+		defer p.inflight.Done()
+		rpcReply := <-replies
+		release()
+		if err := replyError(rpcReply); err != nil {
+			result.Err = err.Error()
+			fmt.Printf("e")
+			resultChannel <- result
+			return
+		}
+		recordReply(client, n, result, start, rpcReply, resultChannel)
+	}()
+}
+
+// replyError returns the error a reply received asynchronously carries, as Execute would, a nil reply is received
+// when the session closed before the reply arrived
+func replyError(reply *netconf.RPCReply) error {
+	if reply == nil {
+		return io.ErrUnexpectedEOF
+	}
+	for idx := range reply.Errors {
+		if reply.Errors[idx].Severity == "error" {
+			return &reply.Errors[idx]
+		}
+	}
+	return nil
+}
+
+// EndPipelines waits for the replies to the requests the client has in flight, so that their results are recorded
+// before the sessions are closed
+func EndPipelines(client *Client) {
+	client.pipelineLock.Lock()
+	defer client.pipelineLock.Unlock()
+	for _, p := range client.pipelines {
+		p.inflight.Wait()
+	}
+}
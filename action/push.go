@@ -0,0 +1,210 @@
+package action
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/result"
+	"github.com/damianoneill/nc-hammer/suite"
+	"github.com/damianoneill/net/netconf"
+)
+
+// pushSession is a session a client holds to a host for its YANG-push subscriptions, the notifications of all of
+// them are received on the session and told apart by their subscription id
+type pushSession struct {
+	session       netconf.Session
+	notifications chan *netconf.Notification
+	received      chan struct{}     // closed once the notifications received on the session have been recorded
+	ids           map[string]string // the ids of the client's named subscriptions, guarded by the client's pushLock
+	dropped       uint64            // the notifications the session dropped as they were not received in time
+
+	subscribeLock sync.Mutex // held while the first request sets up the session's notifications
+	subscribed    bool
+}
+
+// ExecuteYangPush invoked when a YangPush Action is identified, the client's subscriptions to a host share a session
+// that is held until EndSubscriptions is called. A result is recorded for the operation and, as they are received, for
+// each notification of the client's subscriptions.
+func ExecuteYangPush(client *Client, action suite.Action, config *suite.Sshconfig, resultChannel chan result.NetconfResult) {
+	push := action.YangPush
+
+	result := client.newResult(push.Hostname, push.Operation)
+	result.Subscription = push.Name
+	result.Period = milliseconds(push.Period)
+
+	ps, id, err := client.pushSession(config, push, resultChannel)
+	if err != nil {
+		fmt.Printf("E")
+		result.Err = err.Error()
+		_, result.ConnectionFailure = err.(connectionFailure)
+		resultChannel <- result
+		return
+	}
+	result.SessionID = ps.session.ID()
+	result.SubscriptionID = id
+
+	xml, err := push.ToXMLString(id)
+	if err != nil {
+		fmt.Printf("E")
+		result.Err = err.Error()
+		resultChannel <- result
+		return
+	}
+
+	start := time.Now()
+	result.Started = client.sinceStart(start)
+	result.Intended = client.sinceStart(start.Add(-client.Lag))
+	rpcReply, err := ps.execute(netconf.Request(xml))
+	if err != nil {
+		result.Err = err.Error()
+		fmt.Printf("e")
+		resultChannel <- result
+		return
+	}
+	elapsed := time.Since(start)
+	result.When = client.sinceStart(time.Now())
+	result.Latency = float64(elapsed.Nanoseconds() / int64(time.Millisecond))
+	result.MessageID = rpcReply.MessageID
+	client.setReply(rpcReply.Data)
+
+	if push.Operation == "establish-subscription" {
+		result.SubscriptionID = subscriptionID("<reply>" + rpcReply.Data + "</reply>")
+		if result.SubscriptionID == "" {
+			fmt.Printf("e")
+			result.Err = "establish-subscription reply did not include the id of the subscription"
+			resultChannel <- result
+			return
+		}
+	}
+	client.recordSubscription(push, result.SubscriptionID)
+	resultChannel <- result
+}
+
+// connectionFailure is an error establishing a session with the host, as opposed to one with the request
+type connectionFailure struct {
+	error
+}
+
+// pushSession returns the client's YANG-push session to the host, establishing it when the action establishes the
+// client's first subscription to the host, along with the id of the subscription the action refers to. An error
+// establishing the session is a connectionFailure.
+func (c *Client) pushSession(config *suite.Sshconfig, push *suite.YangPush, resultChannel chan result.NetconfResult) (*pushSession, string, error) {
+	c.pushLock.Lock()
+	defer c.pushLock.Unlock()
+	ps := c.push[push.Hostname]
+	if push.Operation != "establish-subscription" {
+		if ps == nil || ps.ids[push.Name] == "" {
+			return nil, "", errors.New("subscription " + push.Name + " has not been established by the client")
+		}
+		return ps, ps.ids[push.Name], nil
+	}
+	if ps != nil {
+		if push.Name != "" && ps.ids[push.Name] != "" {
+			return nil, "", errors.New("subscription " + push.Name + " is already established by the client")
+		}
+		return ps, "", nil
+	}
+
+	ps = &pushSession{notifications: make(chan *netconf.Notification, notificationBuffer), received: make(chan struct{}), ids: make(map[string]string)}
+	session, err := createSubscriptionSession(config.Hostname+":"+strconv.Itoa(config.Port), config.Username, config.Password, &ps.dropped)
+	if err != nil {
+		return nil, "", connectionFailure{err}
+	}
+	ps.session = session
+	c.push[push.Hostname] = ps
+
+	var base result.NetconfResult
+	base.Client = c.ID
+	base.Hostname = push.Hostname
+	base.SessionID = session.ID()
+	base.Population = c.Population
+	base.Phase = c.Phase
+	go ps.receive(c, base, resultChannel)
+	return ps, "", nil
+}
+
+// recordSubscription keeps the id of a subscription the client has established by name, until it is deleted
+func (c *Client) recordSubscription(push *suite.YangPush, id string) {
+	if push.Name == "" {
+		return
+	}
+	c.pushLock.Lock()
+	defer c.pushLock.Unlock()
+	ps := c.push[push.Hostname]
+	switch push.Operation {
+	case "establish-subscription":
+		ps.ids[push.Name] = id
+	case "delete-subscription":
+		delete(ps.ids, push.Name)
+	}
+}
+
+// EndSubscriptions closes the sessions holding the client's YANG-push subscriptions, which ends them, once the
+// notifications already received on them have been recorded
+func EndSubscriptions(client *Client) {
+	client.pushLock.Lock()
+	defer client.pushLock.Unlock()
+	for host, ps := range client.push {
+		ps.session.Close()
+		// the session closes the notifications it was given, a session that was never given them leaves them open
+		ps.subscribeLock.Lock()
+		if !ps.subscribed {
+			close(ps.notifications)
+		}
+		ps.subscribeLock.Unlock()
+		<-ps.received
+		delete(client.push, host)
+	}
+}
+
+// execute sends the request, the session's first request is sent as a subscription so that the notifications of the
+// client's subscriptions are received from then on
+func (ps *pushSession) execute(req netconf.Request) (*netconf.RPCReply, error) {
+	ps.subscribeLock.Lock()
+	if !ps.subscribed {
+		defer ps.subscribeLock.Unlock()
+		ps.subscribed = true
+		return ps.session.Subscribe(req, ps.notifications)
+	}
+	ps.subscribeLock.Unlock()
+	return ps.session.Execute(req)
+}
+
+// receive records a result, based on that of the session, for each notification received until the session closes.
+// The inter-arrival time is measured per subscription, any notifications dropped by the session since the last one
+// was received are counted against the next.
+func (ps *pushSession) receive(client *Client, session result.NetconfResult, resultChannel chan result.NetconfResult) {
+	defer close(ps.received)
+	previous := make(map[string]time.Time)
+	var dropped uint64
+	for notification := range ps.notifications {
+		received := time.Now()
+		id := subscriptionID(notification.Event)
+		event := notificationResult(client, session, notification, received, previous[id])
+		previous[id] = received
+		event.SubscriptionID = id
+		event.Stage = client.stage()
+		total := atomic.LoadUint64(&ps.dropped)
+		event.Dropped = int(total - dropped)
+		dropped = total
+		resultChannel <- event
+	}
+}
+
+// subscriptionID returns the text of the id element within the root element of the XML, the subscription id of an
+// establish-subscription reply or of a YANG-push notification, empty if there is none
+func subscriptionID(data string) string {
+	var element struct {
+		ID string `xml:"id"`
+	}
+	if err := xml.Unmarshal([]byte(data), &element); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(element.ID)
+}
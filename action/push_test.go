@@ -0,0 +1,116 @@
+package action
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/result"
+	"github.com/damianoneill/nc-hammer/suite"
+	"github.com/damianoneill/net/netconf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/damianoneill/nc-hammer/mocks/github.com/damianoneill/net/netconf"
+)
+
+func Test_ExecuteYangPush(t *testing.T) {
+	idReply := func(id string) *netconf.RPCReply {
+		return &netconf.RPCReply{Data: `<id xmlns="urn:ietf:params:xml:ns:yang:ietf-subscribed-notifications">` + id + `</id>`}
+	}
+	var notifications chan *netconf.Notification
+	session := &mocks.Session{}
+	session.On("ID").Return(7)
+	// the first request sets up the session's notifications, the later ones are executed
+	session.On("Subscribe", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		notifications = args.Get(1).(chan *netconf.Notification)
+	}).Return(idReply("42"), nil).Once()
+	session.On("Execute", mock.Anything).Return(idReply("43"), nil).Once()
+	session.On("Execute", mock.Anything).Return(&netconf.RPCReply{Ok: true}, nil)
+	session.On("Close").Run(func(mock.Arguments) { close(notifications) }).Once()
+	original := createSubscriptionSession
+	defer func() { createSubscriptionSession = original }()
+	createSubscriptionSession = func(hostname, username, password string, dropped *uint64) (netconf.Session, error) {
+		return session, nil
+	}
+
+	config := &suite.Sshconfig{Hostname: "10.0.0.1", Port: 830}
+	filter := &suite.Filter{Type: "xpath", Select: "/interfaces"}
+	client := NewClient(3, time.Now())
+	resultChannel := make(chan result.NetconfResult, 10)
+	execute := func(push suite.YangPush) result.NetconfResult {
+		push.Hostname = "10.0.0.1"
+		ExecuteYangPush(client, suite.Action{YangPush: &push}, config, resultChannel)
+		return <-resultChannel
+	}
+
+	r := execute(suite.YangPush{Operation: "establish-subscription", Name: "stats", Filter: filter, Period: time.Second})
+	assert.Equal(t, "", r.Err)
+	assert.Equal(t, "42", r.SubscriptionID)
+	assert.Equal(t, 7, r.SessionID)
+	assert.Equal(t, 1000.0, r.Period)
+	r = execute(suite.YangPush{Operation: "establish-subscription", Name: "stats", Filter: filter, Period: time.Second})
+	assert.Equal(t, "subscription stats is already established by the client", r.Err)
+	r = execute(suite.YangPush{Operation: "establish-subscription", Name: "changes", Filter: filter, OnChange: true})
+	assert.Equal(t, "43", r.SubscriptionID)
+
+	// the notifications of both subscriptions are received on the session, and told apart by their id
+	for _, id := range []string{"42", "43", "42"} {
+		notifications <- &netconf.Notification{XMLName: xml.Name{Local: "push-update"}, EventTime: "2019-01-02T15:04:05Z",
+			Event: `<push-update xmlns="urn:ietf:params:xml:ns:yang:ietf-yang-push"><id>` + id + `</id></push-update>`}
+		time.Sleep(5 * time.Millisecond)
+	}
+	var received []result.NetconfResult
+	for range []int{1, 2, 3} {
+		received = append(received, <-resultChannel)
+	}
+	assert.Equal(t, "42", received[0].SubscriptionID)
+	assert.Equal(t, "push-update", received[0].Event)
+	assert.Equal(t, "", received[0].Err)
+	assert.Equal(t, 0.0, received[1].InterArrival, "the first notification of the subscription")
+	assert.True(t, received[2].InterArrival >= 10, "the time since the subscription's previous notification")
+
+	r = execute(suite.YangPush{Operation: "modify-subscription", Name: "stats", Period: 2 * time.Second})
+	assert.Equal(t, "", r.Err)
+	assert.Equal(t, "42", r.SubscriptionID)
+	r = execute(suite.YangPush{Operation: "delete-subscription", Name: "stats"})
+	assert.Equal(t, "", r.Err)
+	r = execute(suite.YangPush{Operation: "delete-subscription", Name: "stats"})
+	assert.Equal(t, "subscription stats has not been established by the client", r.Err)
+	assert.False(t, r.IsConnectionFailure())
+
+	EndSubscriptions(client)
+	assert.Empty(t, client.push)
+	session.AssertExpectations(t)
+}
+
+func Test_ExecuteYangPushNoID(t *testing.T) {
+	var notifications chan *netconf.Notification
+	session := &mocks.Session{}
+	session.On("ID").Return(7)
+	session.On("Subscribe", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		notifications = args.Get(1).(chan *netconf.Notification)
+	}).Return(&netconf.RPCReply{Ok: true}, nil).Once()
+	session.On("Close").Run(func(mock.Arguments) { close(notifications) }).Once()
+	original := createSubscriptionSession
+	defer func() { createSubscriptionSession = original }()
+	createSubscriptionSession = func(hostname, username, password string, dropped *uint64) (netconf.Session, error) {
+		return session, nil
+	}
+
+	client := NewClient(3, time.Now())
+	resultChannel := make(chan result.NetconfResult, 1)
+	push := &suite.YangPush{Hostname: "10.0.0.1", Operation: "establish-subscription", Filter: &suite.Filter{Type: "xpath", Select: "/interfaces"}, OnChange: true}
+	ExecuteYangPush(client, suite.Action{YangPush: push}, &suite.Sshconfig{Hostname: "10.0.0.1"}, resultChannel)
+	r := <-resultChannel
+	assert.Equal(t, "establish-subscription reply did not include the id of the subscription", r.Err)
+	EndSubscriptions(client)
+	session.AssertExpectations(t)
+}
+
+func Test_subscriptionID(t *testing.T) {
+	assert.Equal(t, "42", subscriptionID(`<reply><id xmlns="urn:ietf:params:xml:ns:yang:ietf-subscribed-notifications">42</id></reply>`))
+	assert.Equal(t, "7", subscriptionID(`<push-change-update xmlns="urn:ietf:params:xml:ns:yang:ietf-yang-push"><id>7</id><datastore-changes/></push-change-update>`))
+	assert.Equal(t, "", subscriptionID(`<reply><ok/></reply>`))
+	assert.Equal(t, "", subscriptionID(`<reply`))
+}
@@ -1,12 +1,19 @@
 package action
 
 import (
+	"context"
 	"time"
 
 	"github.com/damianoneill/nc-hammer/suite"
 )
 
-// ExecuteSleep invoked when a Sleep Action is identified
-func ExecuteSleep(action suite.Action) {
-	time.Sleep(time.Duration(action.Sleep.Duration) * time.Millisecond)
+// ExecuteSleep invoked when a Sleep Action is identified, the client's random source for the action is used to draw
+// the period from its distribution. The sleep is cut short when the context is done.
+func ExecuteSleep(ctx context.Context, client *Client, action suite.Action) {
+	timer := time.NewTimer(action.Sleep.Period(client.Random(action.Sleep, action.Sleep.Seed)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
 }
@@ -0,0 +1,158 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/result"
+	"github.com/damianoneill/nc-hammer/suite"
+	"github.com/damianoneill/net/netconf"
+	"golang.org/x/crypto/ssh"
+)
+
+// notificationBuffer is the number of notifications a subscription holds before they are received, once it is full
+// the session drops any further notifications
+const notificationBuffer = 64
+
+// ExecuteSubscribe invoked when a Subscribe Action is identified, the subscription is created on a session of its own
+// that is closed when the subscription ends. A result is recorded for each notification received and, once the
+// subscription ends, for the create-subscription with the notifications received and dropped.
+func ExecuteSubscribe(ctx context.Context, client *Client, action suite.Action, config *suite.Sshconfig, resultChannel chan result.NetconfResult) {
+	subscribe := action.Subscribe
+
+	result := client.newResult(subscribe.Hostname, "create-subscription")
+	result.Stream = subscribe.GetStream()
+
+	var dropped uint64
+	session, err := createSubscriptionSession(config.Hostname+":"+strconv.Itoa(config.Port), config.Username, config.Password, &dropped)
+	if err != nil {
+		fmt.Printf("E")
+		result.Err = err.Error()
+		result.ConnectionFailure = true
+		resultChannel <- result
+		return
+	}
+	// nolint
+	defer session.Close()
+	result.SessionID = session.ID()
+
+	xml, err := subscribe.ToXMLString()
+	if err != nil {
+		fmt.Printf("E")
+		result.Err = err.Error()
+		resultChannel <- result
+		return
+	}
+
+	notifications := make(chan *netconf.Notification, notificationBuffer)
+	start := time.Now()
+	result.Started = client.sinceStart(start)
+	result.Intended = client.sinceStart(start.Add(-client.Lag))
+	rpcReply, err := session.Subscribe(netconf.Request(xml), notifications)
+	if err != nil {
+		result.Err = err.Error()
+		fmt.Printf("e")
+		resultChannel <- result
+		return
+	}
+	elapsed := time.Since(start)
+	result.When = client.sinceStart(time.Now())
+	result.Latency = float64(elapsed.Nanoseconds() / int64(time.Millisecond))
+	result.MessageID = rpcReply.MessageID
+	client.setReply(rpcReply.Data)
+
+	result.Events = receiveNotifications(ctx, client, subscribe, result, notifications, resultChannel)
+	result.Dropped = int(atomic.LoadUint64(&dropped))
+	resultChannel <- result
+}
+
+// receiveNotifications records a result, based on that of the create-subscription, for each notification received
+// until the subscription's duration or count is reached, the session ends or the context is done. It returns the
+// number of notifications received.
+func receiveNotifications(ctx context.Context, client *Client, subscribe *suite.Subscribe, subscription result.NetconfResult, notifications <-chan *netconf.Notification, resultChannel chan result.NetconfResult) int {
+	var timeout <-chan time.Time
+	if subscribe.Duration > 0 {
+		timer := time.NewTimer(subscribe.Duration)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var events int
+	var previous time.Time
+	for subscribe.Count == 0 || events < subscribe.Count {
+		select {
+		case notification, ok := <-notifications:
+			if !ok {
+				// the session has closed
+				return events
+			}
+			received := time.Now()
+			events++
+			event := notificationResult(client, subscription, notification, received, previous)
+			previous = received
+			resultChannel <- event
+		case <-timeout:
+			return events
+		case <-ctx.Done():
+			return events
+		}
+	}
+	return events
+}
+
+// notificationResult returns the result of a notification received, based on the result of the subscription it was
+// received on, previous is when the subscription last received a notification
+func notificationResult(client *Client, subscription result.NetconfResult, notification *netconf.Notification, received, previous time.Time) result.NetconfResult {
+	event := subscription
+	event.Operation = "notification"
+	event.Event = notification.XMLName.Local
+	event.MessageID = ""
+	event.Started = client.sinceStart(received)
+	event.Intended = event.Started
+	event.When = event.Started
+	event.Latency = 0
+	event.Err = ""
+	if !previous.IsZero() {
+		event.InterArrival = milliseconds(received.Sub(previous))
+	}
+	if delay, err := eventDelay(notification.EventTime, received); err != nil {
+		event.Err = err.Error()
+	} else {
+		event.Latency = delay
+	}
+	return event
+}
+
+// eventDelay returns the milliseconds from the eventTime of a notification to it being received, the delay includes
+// any difference between the clocks of the host and the client
+func eventDelay(eventTime string, received time.Time) (float64, error) {
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(eventTime))
+	if err != nil {
+		return 0, fmt.Errorf("notification eventTime %v is not a valid date-and-time", eventTime)
+	}
+	return milliseconds(received.Sub(t)), nil
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// createSubscriptionSession creates a session whose notifications dropped, as the subscription did not receive them
+// in time, are counted in dropped
+var createSubscriptionSession = func(hostname, username, password string, dropped *uint64) (netconf.Session, error) {
+	sshConfig := &ssh.ClientConfig{
+		User:            username,
+		Auth:            []ssh.AuthMethod{ssh.Password(password)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+	base := netconf.ContextClientTrace(diagnosticContext)
+	trace := *base
+	trace.NotificationDropped = func(n *netconf.Notification) {
+		atomic.AddUint64(dropped, 1)
+		base.NotificationDropped(n)
+	}
+	return netconf.NewRPCSession(netconf.WithClientTrace(diagnosticContext, &trace), sshConfig, hostname)
+}
@@ -0,0 +1,91 @@
+package action
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/result"
+	"github.com/damianoneill/nc-hammer/suite"
+	"github.com/damianoneill/net/netconf"
+	"github.com/stretchr/testify/assert"
+)
+
+// subscribeTo executes the subscription against a test server, once it has been created notify is called and the
+// results are returned when the subscription ends
+func subscribeTo(ctx context.Context, t *testing.T, subscribe *suite.Subscribe, notify func(server *netconf.TestNCServer)) []result.NetconfResult {
+	server := netconf.NewTestNetconfServer(t).WithRequestHandler(netconf.EchoRequestHandler)
+	defer server.Close()
+	config := &suite.Sshconfig{Hostname: "localhost", Port: server.Port(), Username: netconf.TestUserName, Password: netconf.TestPassword}
+
+	client := NewClient(1, time.Now())
+	resultChannel := make(chan result.NetconfResult, 10)
+	done := make(chan struct{})
+	go func() {
+		ExecuteSubscribe(ctx, client, suite.Action{Subscribe: subscribe}, config, resultChannel)
+		close(done)
+	}()
+	// the echoed create-subscription is the client's reply once the subscription is created
+	for deadline := time.Now().Add(5 * time.Second); client.LastReply() == "" && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	notify(server)
+	<-done
+	close(resultChannel)
+
+	var results []result.NetconfResult
+	for r := range resultChannel {
+		results = append(results, r)
+	}
+	return results
+}
+
+func Test_ExecuteSubscribe(t *testing.T) {
+	results := subscribeTo(context.Background(), t, &suite.Subscribe{Hostname: "localhost", Count: 2, Duration: 5 * time.Second}, func(server *netconf.TestNCServer) {
+		server.SendNotification(`<event xmlns="urn:test"><n>1</n></event>`)
+		time.Sleep(10 * time.Millisecond)
+		server.SendNotification(`<event xmlns="urn:test"><n>2</n></event>`)
+	})
+	// a result per notification, then the create-subscription once the count is reached
+	assert.Len(t, results, 3)
+	for _, r := range results[:2] {
+		assert.True(t, r.IsNotification())
+		assert.Equal(t, "NETCONF", r.Stream)
+		// the test server's eventTime is not a date-and-time, so the delay cannot be measured
+		assert.Contains(t, r.Err, "is not a valid date-and-time")
+	}
+	assert.Equal(t, 0.0, results[0].InterArrival)
+	assert.True(t, results[1].InterArrival > 0)
+	assert.Equal(t, "create-subscription", results[2].Operation)
+	assert.Equal(t, "", results[2].Err)
+	assert.Equal(t, 2, results[2].Events)
+	assert.Equal(t, 0, results[2].Dropped)
+}
+
+func Test_ExecuteSubscribeEnds(t *testing.T) {
+	start := time.Now()
+	results := subscribeTo(context.Background(), t, &suite.Subscribe{Hostname: "localhost", Count: 2, Duration: 200 * time.Millisecond}, func(*netconf.TestNCServer) {})
+	assert.True(t, time.Since(start) >= 200*time.Millisecond, "the subscription is held for its duration")
+	assert.Len(t, results, 1)
+	assert.Equal(t, 0, results[0].Events)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := "syslog"
+	results = subscribeTo(ctx, t, &suite.Subscribe{Hostname: "localhost", Stream: &stream, Duration: time.Hour}, func(*netconf.TestNCServer) { cancel() })
+	assert.Len(t, results, 1, "the subscription ends when the context is done")
+	assert.Equal(t, "create-subscription", results[0].Operation)
+	assert.Equal(t, "syslog", results[0].Stream)
+	assert.Equal(t, "", results[0].Err)
+}
+
+func Test_eventDelay(t *testing.T) {
+	received := time.Date(2019, 1, 2, 15, 4, 5, 250*int(time.Millisecond), time.UTC)
+	delay, err := eventDelay("2019-01-02T15:04:05Z", received)
+	assert.NoError(t, err)
+	assert.Equal(t, 250.0, delay)
+	delay, err = eventDelay(" 2019-01-02T16:04:05.1+01:00\n", received)
+	assert.NoError(t, err)
+	assert.Equal(t, 150.0, delay)
+	_, err = eventDelay("yesterday", received)
+	assert.EqualError(t, err, "notification eventTime yesterday is not a valid date-and-time")
+}
@@ -49,9 +49,12 @@ func AnalyseResults(cmd *cobra.Command, ts *suite.TestSuite, results []result.Ne
 		hosts = append(hosts, ts.Configs[idx].Hostname)
 	}
 	log.Printf("Suite defined the following hosts: %v\n", hosts)
-
-	latencies := make(map[string]map[string][]float64)
-	errCount := OrderAndExcludeErrValues(results, latencies)
+	if ts.Scenario != "" {
+		log.Printf("Scenario %v was run\n", ts.Scenario)
+	}
+	if ts.Seed != 0 {
+		log.Printf("Random seed %d, run again with --seed %d to replay the same choices\n", ts.Seed, ts.Seed)
+	}
 
 	// get the largest when time from the results, this is the last action to run
 	var when float64
@@ -62,11 +65,413 @@ func AnalyseResults(cmd *cobra.Command, ts *suite.TestSuite, results []result.Ne
 	}
 	executionTime := time.Duration(when) * time.Millisecond
 
-	log.Printf("%d client(s) started, %d iterations per client, %d seconds wait between starting each client\n", ts.Clients, ts.Iterations, ts.Rampup)
+	// the setup and teardown phases are reported separately from the measured load
+	phased := filterResults(results, func(r *result.NetconfResult) bool { return r.Phase != "" })
+	results = filterResults(results, func(r *result.NetconfResult) bool { return r.Phase == "" })
+	// the pacing misses are counted over all of the iterations, warm-up included
+	loaded := results
+	// the warm-up is left out of the statistics unless asked for
+	var warmups int
+	if include, _ := cmd.Flags().GetBool("include-warmup"); !include {
+		measured := filterResults(results, func(r *result.NetconfResult) bool { return !r.Warmup })
+		warmups = len(results) - len(measured)
+		results = measured
+	}
+	// the notifications received on subscriptions are analysed separately from the requests
+	notifications := filterResults(results, func(r *result.NetconfResult) bool { return r.IsNotification() })
+	results = filterResults(results, func(r *result.NetconfResult) bool { return !r.IsNotification() })
+
+	latencies := make(map[string]map[string][]float64)
+	errCount := OrderAndExcludeErrValues(results, latencies)
+
+	if len(ts.Populations) > 0 {
+		log.Printf("%d population(s) started\n", len(ts.Populations))
+	} else if len(ts.Stages) > 0 {
+		log.Printf("%d stage load profile, lasting %v\n", len(ts.Stages), stagesDuration(ts.Stages))
+	} else if ts.Rate != "" {
+		log.Printf("%d client(s) started, iterations started at %v\n", ts.Clients, ts.Rate)
+	} else if ts.Duration > 0 {
+		log.Printf("%d client(s) started, running for %v, %d seconds wait between starting each client\n", ts.Clients, ts.Duration, ts.Rampup)
+	} else {
+		log.Printf("%d client(s) started, %d iterations per client, %d seconds wait between starting each client\n", ts.Clients, ts.Iterations, ts.Rampup)
+	}
+	if ts.Outcome != nil && ts.Outcome.Reason != "" {
+		log.Printf("Run ended on %v, %v\n", ts.Outcome.Ended, ts.Outcome.Reason)
+	} else if ts.Outcome != nil {
+		log.Printf("Run ended on %v\n", ts.Outcome.Ended)
+	}
+	if isPaced(ts) {
+		misses := int64(pacingMisses(loaded))
+		if ts.Outcome != nil {
+			misses = ts.Outcome.PacingMisses
+		}
+		if ts.Pacing > 0 {
+			log.Printf("Iterations paced every %v, %d iteration(s) overran their pacing\n", ts.Pacing, misses)
+		} else {
+			log.Printf("Iterations paced, %d iteration(s) overran their pacing\n", misses)
+		}
+	}
+	if warmups > 0 {
+		log.Printf("%d warm-up request(s) excluded from the statistics, use --include-warmup to include them\n", warmups)
+	}
 	log.Printf("\nTotal execution time: %v, Suite execution contained %v errors", executionTime, errCount)
 
 	log.Println("")
 
+	renderLatencies(cmd, ts, results)
+
+	for _, population := range ts.GetPopulations() {
+		name := population.Name
+		populated := filterResults(results, func(r *result.NetconfResult) bool { return r.Population == name })
+		if name != "" {
+			log.Printf("\nPopulation %v, %v\n", name, describeLoad(&population.Load))
+			if population.Pacing > 0 {
+				log.Printf("%d iteration(s) overran their pacing\n", pacingMisses(filterResults(loaded, func(r *result.NetconfResult) bool { return r.Population == name })))
+			}
+			renderLatencies(cmd, ts, populated)
+		}
+		// break the statistics down per stage of the load profile
+		for idx, stage := range population.Stages {
+			number := idx + 1
+			log.Printf("\nStage %d: %v\n", number, describeStage(stage))
+			renderLatencies(cmd, ts, filterResults(populated, func(r *result.NetconfResult) bool { return r.Stage == number }))
+		}
+	}
+
+	// the notifications of YANG-push subscriptions have no stream
+	pushed := filterResults(notifications, func(r *result.NetconfResult) bool { return r.Stream == "" })
+	streamed := filterResults(notifications, func(r *result.NetconfResult) bool { return r.Stream != "" })
+	if len(streamed) > 0 {
+		renderSubscriptions(cmd, results, streamed)
+	}
+	if len(pushed) > 0 || len(filterResults(results, func(r *result.NetconfResult) bool { return suite.StringInSlice(r.Operation, suite.YangPushOperations) })) > 0 {
+		renderYangPush(cmd, results, pushed)
+	}
+
+	if len(phased) > 0 {
+		renderPhases(phased)
+	}
+}
+
+// pushStats accumulates the YANG-push subscriptions of a host, with the same name, and the updates they received
+type pushStats struct {
+	trigger       string
+	subscriptions int
+	updates       int
+	first, last   float64 // when the first subscription was established and the last update was received
+	interArrivals []float64
+	drifts        []float64 // how much longer than its period a periodic update took to arrive
+	delays        []float64
+}
+
+// pushSubscription is a YANG-push subscription of a client, identified by the id its host assigned
+type pushSubscription struct {
+	name    string
+	periods []result.NetconfResult // the establish-subscription and any modify-subscription that set its period
+}
+
+// periodAt returns the period of the subscription at the time, 0 if it is not periodic
+func (s *pushSubscription) periodAt(when float64) float64 {
+	var period float64
+	for idx := range s.periods {
+		if s.periods[idx].When <= when || idx == 0 {
+			period = s.periods[idx].Period
+		}
+	}
+	return period
+}
+
+// renderYangPush renders a table of the updates received on the YANG-push subscriptions per host and subscription
+// name; the rate they were received at, the time between them, how far periodic updates drifted from their period
+// and the delay from their eventTime to being received. It is followed by a table of each client's subscriptions.
+func renderYangPush(cmd *cobra.Command, results, notifications []result.NetconfResult) {
+	//nolint
+	hostname, _ := cmd.Flags().GetString("hostname")
+
+	// the subscriptions are identified by client, host and the id the host assigned, their periods change in order
+	operations := filterResults(results, func(r *result.NetconfResult) bool {
+		return suite.StringInSlice(r.Operation, suite.YangPushOperations)
+	})
+	sort.SliceStable(operations, func(i, j int) bool { return operations[i].When < operations[j].When })
+	subscriptions := make(map[string]*pushSubscription)
+	pushes := make(map[[2]string]*pushStats)
+	clients := make(map[[2]string]map[string]int)
+	subscriptionKey := func(r *result.NetconfResult) string {
+		return strconv.Itoa(r.Client) + " " + r.Hostname + " " + r.SubscriptionID
+	}
+	clientStats := func(r *result.NetconfResult) map[string]int {
+		key := [2]string{strconv.Itoa(r.Client), r.Hostname}
+		if clients[key] == nil {
+			clients[key] = make(map[string]int)
+		}
+		return clients[key]
+	}
+	for idx := range operations {
+		r := &operations[idx]
+		counts := clientStats(r)
+		if r.Err != "" {
+			counts["failed"]++
+			continue
+		}
+		counts[r.Operation]++
+		switch r.Operation {
+		case "establish-subscription":
+			name := r.Subscription
+			if name == "" {
+				name = "id " + r.SubscriptionID
+			}
+			subscriptions[subscriptionKey(r)] = &pushSubscription{name: name, periods: []result.NetconfResult{*r}}
+			key := [2]string{r.Hostname, name}
+			if pushes[key] == nil {
+				trigger := "on-change"
+				if r.Period > 0 {
+					trigger = "periodic " + (time.Duration(r.Period) * time.Millisecond).String()
+				}
+				pushes[key] = &pushStats{trigger: trigger, first: r.When}
+			}
+			pushes[key].subscriptions++
+		case "modify-subscription":
+			if s := subscriptions[subscriptionKey(r)]; s != nil && r.Period > 0 {
+				s.periods = append(s.periods, *r)
+			}
+		}
+	}
+
+	var updates, dropped int
+	for idx := range notifications {
+		r := &notifications[idx]
+		counts := clientStats(r)
+		counts["dropped"] += r.Dropped
+		dropped += r.Dropped
+		if r.Event == "subscription-terminated" {
+			counts["terminated"]++
+		}
+		if r.Event != "push-update" && r.Event != "push-change-update" {
+			continue
+		}
+		updates++
+		s := subscriptions[subscriptionKey(r)]
+		if s == nil {
+			s = &pushSubscription{name: "id " + r.SubscriptionID}
+		}
+		key := [2]string{r.Hostname, s.name}
+		if pushes[key] == nil {
+			pushes[key] = &pushStats{trigger: "-", first: r.When}
+		}
+		p := pushes[key]
+		p.updates++
+		p.last = math.Max(p.last, r.When)
+		if r.InterArrival > 0 {
+			p.interArrivals = append(p.interArrivals, r.InterArrival)
+			if period := s.periodAt(r.When); period > 0 {
+				p.drifts = append(p.drifts, r.InterArrival-period)
+			}
+		}
+		if r.Err == "" {
+			p.delays = append(p.delays, r.Latency)
+		}
+	}
+
+	var keys [][2]string
+	for key := range pushes {
+		keys = append(keys, key)
+	}
+	sortPairs(keys)
+	data := [][]string{}
+	for _, key := range keys {
+		if hostname != "" && hostname != key[0] {
+			continue
+		}
+		p := pushes[key]
+		rate, interArrival, drift, maxDrift, delay := "-", "-", "-", "-", "-"
+		if p.last > p.first {
+			rate = fmt.Sprintf("%.2f", float64(p.updates)*1000/(p.last-p.first))
+		}
+		if len(p.interArrivals) > 0 {
+			interArrival = fmt.Sprintf("%.2f", stat.Mean(p.interArrivals, nil))
+		}
+		if len(p.drifts) > 0 {
+			var max float64
+			for _, d := range p.drifts {
+				max = math.Max(max, math.Abs(d))
+			}
+			drift = fmt.Sprintf("%.2f", stat.Mean(p.drifts, nil))
+			maxDrift = fmt.Sprintf("%.2f", max)
+		}
+		if len(p.delays) > 0 {
+			delay = fmt.Sprintf("%.2f", stat.Mean(p.delays, nil))
+		}
+		data = append(data, []string{key[0], key[1], p.trigger, strconv.Itoa(p.subscriptions), strconv.Itoa(p.updates), rate, interArrival, drift, maxDrift, delay})
+	}
+	log.Printf("\nYANG-push subscriptions received %d update(s), %d notification(s) dropped\n", updates, dropped)
+	var table = tablewriter.NewWriter(os.Stdout)
+	renderTable(table, []string{"Host", "Subscription", "Trigger", "Subscriptions", "Updates", "Per Second", "Mean Inter-Arrival", "Mean Drift", "Max Drift", "Mean Delay"}, &data)
+	table.Render()
+
+	// the subscriptions each client established, and those still active when the client ended
+	data = [][]string{}
+	keys = nil
+	for key := range clients {
+		keys = append(keys, key)
+	}
+	sortPairs(keys)
+	// the clients are in numeric order
+	sort.SliceStable(keys, func(i, j int) bool {
+		ci, _ := strconv.Atoi(keys[i][0])
+		cj, _ := strconv.Atoi(keys[j][0])
+		return ci < cj
+	})
+	for _, key := range keys {
+		if hostname != "" && hostname != key[1] {
+			continue
+		}
+		c := clients[key]
+		active := c["establish-subscription"] - c["delete-subscription"] - c["terminated"]
+		if active < 0 {
+			active = 0
+		}
+		data = append(data, []string{key[0], key[1], strconv.Itoa(c["establish-subscription"]), strconv.Itoa(c["modify-subscription"]), strconv.Itoa(c["delete-subscription"]),
+			strconv.Itoa(c["terminated"]), strconv.Itoa(active), strconv.Itoa(c["failed"]), strconv.Itoa(c["dropped"])})
+	}
+	table = tablewriter.NewWriter(os.Stdout)
+	renderTable(table, []string{"Client", "Host", "Established", "Modified", "Deleted", "Terminated", "Active", "Failed", "Dropped"}, &data)
+	table.Render()
+}
+
+// subscriptionStats accumulates the subscriptions to a stream of a host and the notifications they received
+type subscriptionStats struct {
+	subscriptions int
+	dropped       int
+	received      int
+	first, last   float64 // when the first subscription was created and the last notification was received
+	interArrivals []float64
+	delays        []float64
+}
+
+// renderSubscriptions renders a table of the notifications received per host and stream; the rate they were
+// received at, the time between them and the delay from their eventTime to being received
+func renderSubscriptions(cmd *cobra.Command, results, notifications []result.NetconfResult) {
+	//nolint
+	hostname, _ := cmd.Flags().GetString("hostname")
+
+	streams := make(map[[2]string]*subscriptionStats)
+	statsFor := func(r *result.NetconfResult) *subscriptionStats {
+		key := [2]string{r.Hostname, r.Stream}
+		if streams[key] == nil {
+			streams[key] = &subscriptionStats{first: r.When}
+		}
+		return streams[key]
+	}
+	for idx := range results {
+		r := &results[idx]
+		if r.Operation != "create-subscription" || r.Err != "" {
+			continue
+		}
+		s := statsFor(r)
+		s.subscriptions++
+		s.dropped += r.Dropped
+		s.first = math.Min(s.first, r.When)
+	}
+	var received, dropped int
+	for idx := range notifications {
+		r := &notifications[idx]
+		s := statsFor(r)
+		s.received++
+		s.last = math.Max(s.last, r.When)
+		if r.InterArrival > 0 {
+			s.interArrivals = append(s.interArrivals, r.InterArrival)
+		}
+		if r.Err == "" {
+			s.delays = append(s.delays, r.Latency)
+		}
+	}
+
+	var keys [][2]string
+	for key := range streams {
+		keys = append(keys, key)
+	}
+	sortPairs(keys)
+	data := [][]string{}
+	for _, key := range keys {
+		if hostname != "" && hostname != key[0] {
+			continue
+		}
+		s := streams[key]
+		received += s.received
+		dropped += s.dropped
+		rate, interArrival, delay, p99 := "-", "-", "-", "-"
+		if s.last > s.first {
+			rate = fmt.Sprintf("%.2f", float64(s.received)*1000/(s.last-s.first))
+		}
+		if len(s.interArrivals) > 0 {
+			interArrival = fmt.Sprintf("%.2f", stat.Mean(s.interArrivals, nil))
+		}
+		if len(s.delays) > 0 {
+			sort.Float64s(s.delays)
+			delay = fmt.Sprintf("%.2f", stat.Mean(s.delays, nil))
+			p99 = fmt.Sprintf("%.2f", stat.Quantile(0.99, stat.Empirical, s.delays, nil))
+		}
+		data = append(data, []string{key[0], key[1], strconv.Itoa(s.subscriptions), strconv.Itoa(s.received), strconv.Itoa(s.dropped), rate, interArrival, delay, p99})
+	}
+	log.Printf("\nSubscriptions received %d notification(s), %d dropped\n", received, dropped)
+	var table = tablewriter.NewWriter(os.Stdout)
+	renderTable(table, []string{"Host", "Stream", "Subscriptions", "Notifications", "Dropped", "Per Second", "Mean Inter-Arrival", "Mean Delay", "99% Delay"}, &data)
+	table.Render()
+}
+
+// renderPhases renders a table of the requests and errors of the setup and teardown phases, per phase, host and
+// operation, in the order the phases run
+func renderPhases(results []result.NetconfResult) {
+	var errCount int
+	data := [][]string{}
+	for _, phase := range []string{"init", "client-setup", "client-teardown", "teardown"} {
+		inPhase := filterResults(results, func(r *result.NetconfResult) bool { return r.Phase == phase })
+		SortResults(inPhase)
+		for idx := 0; idx < len(inPhase); {
+			var requests, errors int
+			host, operation := inPhase[idx].Hostname, inPhase[idx].Operation
+			for ; idx < len(inPhase) && inPhase[idx].Hostname == host && inPhase[idx].Operation == operation; idx++ {
+				requests++
+				if inPhase[idx].Err != "" {
+					errors++
+				}
+			}
+			errCount += errors
+			data = append(data, []string{phase, host, operation, strconv.Itoa(requests), strconv.Itoa(errors)})
+		}
+	}
+	log.Printf("\nSetup and teardown phases contained %v errors\n", errCount)
+	var table = tablewriter.NewWriter(os.Stdout)
+	renderTable(table, []string{"Phase", "Host", "Operation", "Requests", "Errors"}, &data)
+	table.Render()
+}
+
+// sortPairs sorts the keys, each a pair of for e.g. host and operation, by their first and then their second value
+func sortPairs(keys [][2]string) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+}
+
+// filterResults returns the results that match
+func filterResults(results []result.NetconfResult, match func(*result.NetconfResult) bool) []result.NetconfResult {
+	var filtered []result.NetconfResult
+	for idx := range results {
+		if match(&results[idx]) {
+			filtered = append(filtered, results[idx])
+		}
+	}
+	return filtered
+}
+
+// renderLatencies renders a table of the latency statistics of the results, per host and operation
+func renderLatencies(cmd *cobra.Command, ts *suite.TestSuite, results []result.NetconfResult) {
+	latencies := make(map[string]map[string][]float64)
+	OrderAndExcludeErrValues(results, latencies)
+
 	//nolint
 	op, _ := cmd.Flags().GetString("operation")
 	//nolint
@@ -74,12 +479,17 @@ func AnalyseResults(cmd *cobra.Command, ts *suite.TestSuite, results []result.Ne
 
 	keys := SortLatencies(latencies) // returns sorted key index to latencies
 
+	// an open loop load records when each request was intended to start, so latency can be corrected for coordinated omission
+	openLoop := isOpenLoop(ts, results)
+	corrected := correctedLatencies(results)
+
 	data := [][]string{}
 	for _, k := range keys {
 		host := k
 		operations := latencies[k]
 		for operation, latencies := range operations {
-			if op != "" && op != operation {
+			// an operation on NMDA datastores matches all of its datastores
+			if op != "" && op != operation && !strings.HasPrefix(operation, op+" (") {
 				continue
 			}
 			if hostname != "" && hostname != host {
@@ -89,14 +499,84 @@ func AnalyseResults(cmd *cobra.Command, ts *suite.TestSuite, results []result.Ne
 			tps := 1000 / mean
 			variance := stat.Variance(latencies, nil)
 			stddev := math.Sqrt(variance)
-			data = append(data, []string{host, operation, strconv.FormatBool(ts.Configs.IsReuseConnection(host)), strconv.Itoa(len(latencies)), fmt.Sprintf("%.2f", tps), fmt.Sprintf("%.2f", mean), fmt.Sprintf("%.2f", variance), fmt.Sprintf("%.2f", stddev)})
+			row := []string{host, operation, strconv.FormatBool(ts.Configs.IsReuseConnection(host)), strconv.Itoa(len(latencies)), fmt.Sprintf("%.2f", tps), fmt.Sprintf("%.2f", mean), fmt.Sprintf("%.2f", variance), fmt.Sprintf("%.2f", stddev)}
+			if openLoop {
+				c := corrected[host][operation]
+				row = append(row, fmt.Sprintf("%.2f", stat.Mean(c, nil)), fmt.Sprintf("%.2f", stat.Quantile(0.99, stat.Empirical, c, nil)))
+			}
+			data = append(data, row)
 		}
 	}
+	header := []string{"Host", "Operation", "Reuse Connection", "Requests", "TPS", "Mean", "Variance", "Std Deviation"}
+	if openLoop {
+		header = append(header, "Corrected Mean", "Corrected 99%")
+	}
 	var table = tablewriter.NewWriter(os.Stdout)
-	renderTable(table, []string{"Host", "Operation", "Reuse Connection", "Requests", "TPS", "Mean", "Variance", "Std Deviation"}, &data)
+	renderTable(table, header, &data)
 	table.Render()
 }
 
+// isOpenLoop returns true if any of the results were sent by the clients of an open loop load, the suite's own load
+// or that of one of its populations
+func isOpenLoop(ts *suite.TestSuite, results []result.NetconfResult) bool {
+	open := make(map[string]bool)
+	for _, population := range ts.GetPopulations() {
+		if population.IsOpenLoop() {
+			open[population.Name] = true
+		}
+	}
+	for idx := range results {
+		if open[results[idx].Population] {
+			return true
+		}
+	}
+	return false
+}
+
+// isPaced returns true if the iterations of the suite's own load or of any of its populations are paced
+func isPaced(ts *suite.TestSuite) bool {
+	for _, population := range ts.GetPopulations() {
+		if population.Pacing > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// pacingMisses returns the number of iterations of the results that started late, as the client's previous iteration
+// overran its pacing
+func pacingMisses(results []result.NetconfResult) int {
+	missed := make(map[[2]int]bool)
+	for idx := range results {
+		if results[idx].PacingMiss {
+			missed[[2]int{results[idx].Client, results[idx].Iteration}] = true
+		}
+	}
+	return len(missed)
+}
+
+// correctedLatencies returns the sorted latencies, corrected for coordinated omission, of the results not in error
+// keyed by host and operation key
+func correctedLatencies(results []result.NetconfResult) map[string]map[string][]float64 {
+	corrected := make(map[string]map[string][]float64)
+	for idx := range results {
+		if results[idx].Err != "" {
+			continue
+		}
+		if corrected[results[idx].Hostname] == nil {
+			corrected[results[idx].Hostname] = make(map[string][]float64)
+		}
+		operation := results[idx].OperationKey()
+		corrected[results[idx].Hostname][operation] = append(corrected[results[idx].Hostname][operation], results[idx].CorrectedLatency())
+	}
+	for _, operations := range corrected {
+		for _, latencies := range operations {
+			sort.Float64s(latencies)
+		}
+	}
+	return corrected
+}
+
 // OrderAndExcludeErrValues Orders the results and removes errors from output. Returns number of errors found.
 func OrderAndExcludeErrValues(results []result.NetconfResult, latencies map[string]map[string][]float64) int {
 	var errCount int
@@ -108,7 +588,8 @@ func OrderAndExcludeErrValues(results []result.NetconfResult, latencies map[stri
 		if results[idx].Err != "" {
 			errCount++
 		} else {
-			latencies[results[idx].Hostname][results[idx].Operation] = append(latencies[results[idx].Hostname][results[idx].Operation], results[idx].Latency)
+			operation := results[idx].OperationKey()
+			latencies[results[idx].Hostname][operation] = append(latencies[results[idx].Hostname][operation], results[idx].Latency)
 		}
 	}
 
@@ -139,8 +620,9 @@ func renderTable(table *tablewriter.Table, header []string, data *[][]string) {
 
 func init() {
 	RootCmd.AddCommand(AnalyseCmd)
-	AnalyseCmd.Flags().StringP("operation", "o", "", "filter based on operation type; get, get-config or edit-config")
+	AnalyseCmd.Flags().StringP("operation", "o", "", "filter based on operation type; for e.g. get, get-config, edit-config or get-data")
 	AnalyseCmd.Flags().StringP("hostname", "", "", "filter based on host name or ip")
+	AnalyseCmd.Flags().Bool("include-warmup", false, "include the requests sent in the warm-up in the statistics")
 }
 
 // SortLatencies Sorts keys of latencies Map to allow for ordered iteration of map
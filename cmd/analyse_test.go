@@ -300,3 +300,224 @@ func TestAnalyseCmdRun(t *testing.T) {
 		}
 	}
 }
+
+func TestAnalyseResultsOpenLoop(t *testing.T) {
+	mockTestSuite.Rate = "10/s"
+	defer func() { mockTestSuite.Rate = "" }()
+
+	late := mts1
+	late.Intended, late.Started = 100, 400
+
+	stdout, stderr := redirectOutput([]result.NetconfResult{late, mts2})
+
+	assert.Contains(t, stderr, "iterations started at 10/s")
+	assert.Contains(t, stdout, "CORRECTED MEAN CORRECTED 99%")
+	assert.Contains(t, stdout, "10.0.0.1 edit-config false 1 3.47 288.00 NaN NaN 588.00 588.00")
+}
+
+func TestAnalyseResultsOpenLoopPopulation(t *testing.T) {
+	mockTestSuite.Populations = []Population{{Name: "monitoring", Load: Load{Clients: 5, Iterations: 10, Rate: "10/s"}}, {Name: "provisioning", Load: Load{Clients: 3, Iterations: 10}}}
+	defer func() { mockTestSuite.Populations = nil }()
+
+	monitoring, provisioning := mts1, mts2
+	monitoring.Population, provisioning.Population = "monitoring", "provisioning"
+	monitoring.Intended, monitoring.Started = 100, 400
+
+	stdout, _ := redirectOutput([]result.NetconfResult{monitoring, provisioning})
+
+	// the corrected latency is shown for the overall table and that of the open loop population, not the closed loop one
+	assert.Equal(t, 2, strings.Count(stdout, "CORRECTED MEAN CORRECTED 99%"))
+	assert.Contains(t, stdout, "10.0.0.1 edit-config false 1 3.47 288.00 NaN NaN 588.00 588.00")
+}
+
+func TestAnalyseResultsStages(t *testing.T) {
+	mockTestSuite.Stages = []Stage{{Name: "ramp-up", Ramp: time.Second, Clients: 2}, {Duration: time.Second, Clients: 2}}
+	defer func() { mockTestSuite.Stages = nil }()
+
+	first, second := mts1, mts2
+	first.Stage, second.Stage = 1, 2
+
+	stdout, stderr := redirectOutput([]result.NetconfResult{first, second})
+
+	assert.Contains(t, stderr, "Stage 1: ramp-up, 2 client(s) ramped over 1s, held for 0s")
+	assert.Contains(t, stderr, "Stage 2: 2 client(s) ramped over 0s, held for 1s")
+	// overall table followed by a table per stage
+	assert.Equal(t, 3, strings.Count(stdout, "HOST OPERATION"))
+}
+
+func TestAnalyseResultsPopulations(t *testing.T) {
+	mockTestSuite.Populations = []Population{{Name: "monitoring", Load: Load{Clients: 50, Iterations: 10}}, {Name: "provisioning", Load: Load{Clients: 3, Iterations: 10}}}
+	defer func() { mockTestSuite.Populations = nil }()
+
+	monitoring, provisioning := mts2, mts1
+	monitoring.Population, provisioning.Population = "monitoring", "provisioning"
+
+	stdout, stderr := redirectOutput([]result.NetconfResult{monitoring, provisioning})
+
+	assert.Contains(t, stderr, "2 population(s) started")
+	assert.Contains(t, stderr, "Population monitoring, 50 client(s), 10 iterations per client")
+	assert.Contains(t, stderr, "Population provisioning, 3 client(s), 10 iterations per client")
+	// overall table followed by a table per population
+	assert.Equal(t, 3, strings.Count(stdout, "HOST OPERATION"))
+}
+
+func TestAnalyseResultsPhases(t *testing.T) {
+	setup, teardown := mts1, mts5
+	setup.Phase, teardown.Phase = "client-setup", "teardown"
+
+	stdout, stderr := redirectOutput([]result.NetconfResult{mts2, setup, teardown})
+
+	// the phases are excluded from the measured load and reported on their own
+	assert.Contains(t, stderr, "Suite execution contained 0 errors")
+	assert.Contains(t, stderr, "Setup and teardown phases contained 1 errors")
+	assert.Contains(t, stdout, "10.0.0.2 get-config false 1")
+	assert.NotContains(t, stdout, "10.0.0.1 edit-config false")
+	assert.Contains(t, stdout, "PHASE HOST OPERATION REQUESTS ERRORS")
+	assert.Contains(t, stdout, "client-setup 10.0.0.1 edit-config 1 0")
+	assert.Contains(t, stdout, "teardown 10.0.0.2 kill-session 1 1")
+}
+
+func TestAnalyseResultsAbort(t *testing.T) {
+	mockTestSuite.Outcome = &Outcome{Ended: "abort", Reason: "5 consecutive connection failures"}
+	defer func() { mockTestSuite.Outcome = nil }()
+
+	_, stderr := redirectOutput([]result.NetconfResult{mts1})
+
+	assert.Contains(t, stderr, "Run ended on abort, 5 consecutive connection failures")
+}
+
+func TestAnalyseResultsPacing(t *testing.T) {
+	mockTestSuite.Pacing = time.Second
+	mockTestSuite.Outcome = &Outcome{Ended: "iterations", PacingMisses: 3}
+	defer func() { mockTestSuite.Pacing, mockTestSuite.Outcome = 0, nil }()
+
+	_, stderr := redirectOutput([]result.NetconfResult{mts1})
+
+	assert.Contains(t, stderr, "Iterations paced every 1s, 3 iteration(s) overran their pacing")
+}
+
+func TestAnalyseResultsPopulationPacing(t *testing.T) {
+	mockTestSuite.Populations = []Population{{Name: "monitoring", Load: Load{Clients: 2, Iterations: 10, Pacing: time.Second}}, {Name: "provisioning", Load: Load{Clients: 3, Iterations: 10}}}
+	defer func() { mockTestSuite.Populations = nil }()
+
+	// two requests of the same late iteration count as a single miss
+	first, second, third := mts1, mts2, mts1
+	first.Population, second.Population, third.Population = "monitoring", "monitoring", "monitoring"
+	first.Client, second.Client, third.Client = 1, 1, 1
+	first.Iteration, second.Iteration, third.Iteration = 2, 2, 3
+	first.PacingMiss, second.PacingMiss = true, true
+	provisioning := mts2
+	provisioning.Population = "provisioning"
+
+	_, stderr := redirectOutput([]result.NetconfResult{first, second, third, provisioning})
+
+	assert.Contains(t, stderr, "Iterations paced, 1 iteration(s) overran their pacing")
+	assert.Contains(t, stderr, "Population monitoring, 2 client(s), 10 iterations per client, 0 seconds wait between starting each client, iterations paced every 1s 1 iteration(s) overran their pacing")
+	assert.Equal(t, 2, strings.Count(stderr, "overran their pacing"))
+}
+
+func TestAnalyseResultsWarmup(t *testing.T) {
+	warmup := mts2
+	warmup.Warmup = true
+
+	stdout, stderr := redirectOutput([]result.NetconfResult{mts1, warmup})
+	assert.Contains(t, stderr, "1 warm-up request(s) excluded from the statistics, use --include-warmup to include them")
+	assert.NotContains(t, stdout, "10.0.0.2 get-config")
+
+	var include bool
+	mockCmd.Flags().BoolVar(&include, "include-warmup", true, "")
+	defer mockCmd.ResetFlags()
+	stdout, stderr = redirectOutput([]result.NetconfResult{mts1, warmup})
+	assert.NotContains(t, stderr, "warm-up")
+	assert.Contains(t, stdout, "10.0.0.2 get-config")
+}
+
+func TestAnalyseResultsScenario(t *testing.T) {
+	mockTestSuite.Scenario = "soak"
+	defer func() { mockTestSuite.Scenario = "" }()
+
+	_, stderr := redirectOutput([]result.NetconfResult{mts1})
+
+	assert.Contains(t, stderr, "Scenario soak was run")
+}
+
+func TestAnalyseResultsSeed(t *testing.T) {
+	mockTestSuite.Seed = 42
+	defer func() { mockTestSuite.Seed = 0 }()
+
+	_, stderr := redirectOutput([]result.NetconfResult{mts1})
+
+	assert.Contains(t, stderr, "Random seed 42, run again with --seed 42 to replay the same choices")
+}
+
+func TestAnalyseResultsDatastores(t *testing.T) {
+	operational, running := mts1, mts1
+	operational.Operation, operational.Datastore = "get-data", "operational"
+	running.Operation, running.Datastore = "get-data", "running"
+
+	stdout, _ := redirectOutput([]result.NetconfResult{operational, running})
+	assert.Contains(t, stdout, "10.0.0.1 get-data (operational)")
+	assert.Contains(t, stdout, "10.0.0.1 get-data (running)")
+
+	// filtering on the operation matches each of its datastores
+	var flag string
+	mockCmd.Flags().StringVar(&flag, "operation", "get-data", "")
+	defer mockCmd.ResetFlags()
+	stdout, _ = redirectOutput([]result.NetconfResult{operational, running, mts2})
+	assert.Equal(t, 2, strings.Count(stdout, "get-data ("))
+	assert.NotContains(t, stdout, "get-config")
+}
+
+func TestAnalyseResultsSubscriptions(t *testing.T) {
+	subscription := result.NetconfResult{Client: 1, SessionID: 7, Hostname: "10.0.0.1", Operation: "create-subscription", Stream: "NETCONF", When: 1000, Latency: 12, Events: 3, Dropped: 1}
+	var notifications []result.NetconfResult
+	for idx, when := range []float64{1250, 1500, 2000} {
+		n := subscription
+		n.Operation, n.When, n.Latency, n.Events, n.Dropped = "notification", when, float64(10*(idx+1)), 0, 0
+		if idx > 0 {
+			n.InterArrival = when - notifications[idx-1].When
+		}
+		notifications = append(notifications, n)
+	}
+	late := notifications[2]
+	late.Err = "notification eventTime x is not a valid date-and-time"
+	late.When = 2000
+
+	stdout, stderr := redirectOutput(append([]result.NetconfResult{subscription, late}, notifications...))
+	assert.Contains(t, stderr, "Subscriptions received 4 notification(s), 1 dropped")
+	// the notifications are not requests, the create-subscription is
+	assert.Contains(t, stdout, "10.0.0.1 create-subscription")
+	assert.NotContains(t, stdout, "10.0.0.1 notification")
+	assert.Contains(t, stdout, "10.0.0.1 NETCONF 1 4 1 4.00 416.67 20.00 30.00")
+}
+
+func TestAnalyseResultsYangPush(t *testing.T) {
+	establish := result.NetconfResult{Client: 2, SessionID: 9, Hostname: "10.0.0.1", Operation: "establish-subscription", When: 1000, Latency: 5, Subscription: "stats", SubscriptionID: "42", Period: 1000}
+	modify := establish
+	modify.Operation, modify.When, modify.Period = "modify-subscription", 3600, 500
+	failed := establish
+	failed.Client, failed.SubscriptionID, failed.Err = 3, "", "subscription stats is already established by the client"
+	results := []result.NetconfResult{establish, modify, failed}
+	// periodic updates 1010ms, 990ms and, after the period is modified, 520ms apart
+	var previous float64
+	for idx, when := range []float64{1500, 2510, 3500, 4020} {
+		update := result.NetconfResult{Client: 2, SessionID: 9, Hostname: "10.0.0.1", Operation: "notification", Event: "push-update", When: when, Latency: 10, SubscriptionID: "42"}
+		if idx > 0 {
+			update.InterArrival = when - previous
+		}
+		previous = when
+		results = append(results, update)
+	}
+	results[len(results)-1].Dropped = 2
+	terminated := results[len(results)-1]
+	terminated.Event, terminated.Dropped = "subscription-terminated", 0
+	results = append(results, terminated)
+
+	stdout, stderr := redirectOutput(results)
+	assert.Contains(t, stderr, "YANG-push subscriptions received 4 update(s), 2 notification(s) dropped")
+	assert.Contains(t, stdout, "10.0.0.1 establish-subscription")
+	// mean drift (10 - 10 + 20) / 3, the largest drift is 20
+	assert.Contains(t, stdout, "10.0.0.1 stats periodic 1s 1 4 1.32 840.00 6.67 20.00 10.00")
+	assert.Contains(t, stdout, "2 10.0.0.1 1 1 0 1 0 0 2")
+	assert.Contains(t, stdout, "3 10.0.0.1 0 0 0 0 0 1 0")
+}
@@ -1,9 +1,16 @@
 package cmd
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/damianoneill/nc-hammer/action"
@@ -13,7 +20,17 @@ import (
 )
 
 var (
-	diagFlag = false
+	diagFlag        = false
+	durationFlag    time.Duration
+	gracePeriodFlag = 10 * time.Second
+	workersFlag     []string
+	workerTokenFlag = os.Getenv(tokenEnv)
+	dryRunFlag      = false
+	scenarioFlag    string
+	seedFlag        int64
+
+	// exit is replaced in tests
+	exit = os.Exit
 )
 
 // runCmd represents the run command
@@ -30,11 +47,41 @@ var runCmd = &cobra.Command{
 		if ts, err := suite.NewTestSuite(args[0]); err != nil {
 			log.Fatalf("Problem with YAML file: %v ", err)
 		} else {
+			if err = selectScenario(ts, scenarioFlag); err != nil {
+				log.Fatalf("Problem with YAML file: %v ", err)
+			}
+			if seedFlag != 0 {
+				ts.Seed = seedFlag
+			}
+			if durationFlag > 0 {
+				ts.Duration = durationFlag
+				for idx := range ts.Populations {
+					ts.Populations[idx].Duration = durationFlag
+				}
+			}
+			if dryRunFlag {
+				if err := runDryRun(ts, os.Stdout); err != nil {
+					log.Fatalf("Problem with dry run: %v ", err)
+				}
+				return
+			}
 			runTestSuite(ts)
 		}
 	},
 }
 
+// selectScenario selects the named scenario of the suite, a suite that defines its load only in scenarios requires
+// one, even when it defines init or teardown blocks at the top level
+func selectScenario(ts *suite.TestSuite, name string) error {
+	if name != "" {
+		return ts.SelectScenario(name)
+	}
+	if len(ts.Scenarios) > 0 && !ts.HasLoad() {
+		return errors.New("choose one of the scenarios " + strings.Join(ts.ScenarioNames(), ", ") + " with --scenario")
+	}
+	return nil
+}
+
 func runTestSuite(ts *suite.TestSuite) {
 
 	// Initialise the context used to create netconf sessions, to enable diagnostics if requested.
@@ -42,73 +89,538 @@ func runTestSuite(ts *suite.TestSuite) {
 
 	start := time.Now()
 	log.Printf("Testsuite %v started at %v\n", ts.File, start.Format("Mon Jan _2 15:04:05 2006"))
-	log.Printf(" > %d client(s), %d iterations per client, %d seconds wait between starting each client\n", ts.Clients, ts.Iterations, ts.Rampup)
+	// the seed is recorded in the archived suite, so that the run can be replayed
+	if ts.Seed == 0 {
+		ts.Seed = start.UnixNano()
+	}
+	if ts.Scenario != "" {
+		log.Printf(" > scenario %v\n", ts.Scenario)
+	}
+	populations := ts.GetPopulations()
+	for idx := range populations {
+		if populations[idx].Name != "" {
+			log.Printf(" > population %v, %v\n", populations[idx].Name, describeLoad(&populations[idx].Load))
+		} else {
+			log.Printf(" > %v\n", describeLoad(&populations[idx].Load))
+		}
+	}
 
-	// handle results in separate goroutine
+	// when workers are defined they execute the load, they are prepared up front so that any problem is found early
+	var workers []*worker
+	if len(workersFlag) > 0 {
+		var err error
+		if workers, err = prepareWorkers(ts, workersFlag); err != nil {
+			log.Fatalf("Problem with workers: %v ", err)
+		}
+		log.Printf(" > clients split across %d worker(s) %v\n", len(workers), workersFlag)
+	}
+
+	// handle results in separate goroutine, the actions' results are relayed to it until the run stops
 	resultChannel := make(chan result.NetconfResult)
 	handleResultsFinished := make(chan bool)
 	go result.HandleResults(resultChannel, handleResultsFinished, ts)
+	actionChannel := make(chan result.NetconfResult)
+	stopRelay := make(chan struct{})
+	clientsDone := make(chan struct{})
+	// any abort rules are checked against the results as they are relayed
+	var monitor *abortMonitor
+	var aborted <-chan string
+	if ts.Abort != nil {
+		monitor = newAbortMonitor(ts.Abort)
+		aborted = monitor.aborted
+	}
+	go relayResults(actionChannel, resultChannel, stopRelay, clientsDone, gracePeriodFlag, monitor)
 
-	// check first for an init block, this runs at the start, actions are sequential, it only runs once
-	// if the tester has specified more than one init block, these are ignored
-	if block := ts.GetInitBlock(); block != nil {
-		log.Printf(" > Init Block defined, executing %d init actions sequentially up front", len(block.Actions))
-		for _, a := range block.Actions {
-			action.Execute(start, 0, ts, a, resultChannel)
+	// on an interrupt clients stop dispatching new actions, a second interrupt exits immediately
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	interrupted := make(chan struct{})
+	go handleSignals(signals, cancel, interrupted)
+
+	// check first for init blocks, these run at the start, actions are sequential, they only run once
+	if blocks := ts.GetBlocks("init"); len(blocks) > 0 {
+		log.Printf(" > Init Block defined, executing %d init actions sequentially up front", countActions(blocks))
+		client := action.NewClient(0, start)
+		client.Seed = ts.Seed
+		client.Counters = ts.Counters()
+		handlePhase(ts, client, "init", blocks, actionChannel)
+		action.EndSubscriptions(client)
+	}
+
+	loadStart := time.Now()
+	var finished <-chan struct{}
+	var pacingMisses int64
+	if len(workers) > 0 {
+		finished = startWorkers(ctx, workers, start, &pacingMisses, actionChannel)
+	} else {
+		finished = runLoad(ctx, ts, wholeRun, start, &pacingMisses, actionChannel)
+	}
+	go func() {
+		<-finished
+		close(clientsDone)
+	}()
+
+	ts.Outcome = &suite.Outcome{Ended: "iterations"}
+	select {
+	case <-finished:
+		// teardown blocks run once all of the clients have finished, actions are sequential, they only run once
+		if blocks := ts.GetBlocks("teardown"); len(blocks) > 0 {
+			log.Printf("\n > Teardown Block defined, executing %d teardown actions sequentially", countActions(blocks))
+			client := action.NewClient(0, start)
+			client.Seed = ts.Seed
+			client.Counters = ts.Counters()
+			handlePhase(ts, client, "teardown", blocks, actionChannel)
+			action.EndSubscriptions(client)
 		}
+	case <-interrupted:
+		// in-flight actions are given a bounded time to complete, teardown blocks are not run
+		ts.Outcome.Ended = "interrupt"
+		awaitInFlight(finished, gracePeriodFlag)
+	case reason := <-aborted:
+		log.Printf("\n > Aborting, %v, no new actions will be started, waiting up to %v for in-flight requests\n", reason, gracePeriodFlag)
+		cancel()
+		ts.Outcome.Ended = "abort"
+		ts.Outcome.Reason = reason
+		awaitInFlight(finished, gracePeriodFlag)
 	}
-	// create concurrent sessions for each of the defined clients
-	clientWg := sync.WaitGroup{}
-	for cID := 0; cID < ts.Clients; cID++ {
-		clientWg.Add(1)
-		go handleBlocks(start, ts, cID, &clientWg, resultChannel)
-		// handle rampup for each client
-		var waitDuration = float32(ts.Rampup) / float32(ts.Clients)
-		time.Sleep(time.Duration(int(1000*waitDuration)) * time.Millisecond)
+
+	switch {
+	case ts.Outcome.Ended == "interrupt" || ts.Outcome.Ended == "abort":
+	case ts.Duration > 0 && time.Since(loadStart) >= ts.Duration:
+		ts.Outcome.Ended = "duration"
+	case len(ts.Populations) > 0:
+		ts.Outcome.Ended = "populations"
+	case len(ts.Stages) > 0:
+		ts.Outcome.Ended = "stages"
 	}
-	clientWg.Wait()
 
-	// close the results channel and wait for the results goroutine to finish
-	close(resultChannel)
-	<-handleResultsFinished
+	ts.Outcome.PacingMisses = atomic.LoadInt64(&pacingMisses)
 
-	// close any cached sessions
+	// close any cached sessions, this also unblocks any requests still in-flight after an interrupt
 	action.CloseAllSessions()
 
+	// stop relaying, close the results channel and wait for the results goroutine to finish
+	close(stopRelay)
+	<-handleResultsFinished
+
 	log.Printf("\nTestsuite completed in %v\n", time.Since(start))
 }
 
-// handleBlocks determines the block type and processes the actions appropriately
-func handleBlocks(start time.Time, ts *suite.TestSuite, cID int, clientWg *sync.WaitGroup, resultChannel chan result.NetconfResult) {
-	for i := 0; i < ts.Iterations; i++ {
-		for _, block := range ts.Blocks {
-			// block sections are executed sequentially, individual blocks may execute actions sequentially or councurrently
-			switch block.Type {
-			case "sequential":
-				for _, a := range block.Actions {
-					action.Execute(start, cID, ts, a, resultChannel)
+// runLoad starts the populations, running the share of their clients in part, the returned channel is closed once
+// all of the clients have finished. Iterations that overrun their pacing are counted in misses.
+func runLoad(ctx context.Context, ts *suite.TestSuite, part partition, start time.Time, misses *int64, resultChannel chan result.NetconfResult) <-chan struct{} {
+	// the context is only done when the run is interrupted or aborted, unlike those the clients are given below it
+	ctx = context.WithValue(ctx, stoppedKey{}, ctx.Done())
+	// when a duration is defined, clients stop dispatching new actions once the deadline passes
+	if ts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		time.AfterFunc(ts.Duration, cancel)
+	}
+
+	// the populations run alongside each other, client ids are unique across the populations and the stages
+	populations := ts.GetPopulations()
+	clientWg := sync.WaitGroup{}
+	populationWg := sync.WaitGroup{}
+	var firstID int
+	for idx := range populations {
+		populationWg.Add(1)
+		go func(population *suite.Population, firstID int) {
+			defer populationWg.Done()
+			runPopulation(ctx, ts, population, firstID, start, part, misses, &clientWg, resultChannel)
+		}(&populations[idx], firstID)
+		firstID += populations[idx].ClientIDs()
+	}
+	finished := make(chan struct{})
+	go func() {
+		populationWg.Wait()
+		// wait for any in-flight actions to drain
+		clientWg.Wait()
+		close(finished)
+	}()
+	return finished
+}
+
+// awaitInFlight waits for up to the grace period for the in-flight actions to finish
+func awaitInFlight(finished <-chan struct{}, gracePeriod time.Duration) {
+	select {
+	case <-finished:
+	case <-time.After(gracePeriod):
+		log.Printf("\n > In-flight requests did not complete within %v, their results are discarded\n", gracePeriod)
+	}
+}
+
+// relayResults passes the results of actions on until stop is closed, it then closes results and discards the results
+// of any actions still in-flight, until clientsDone is closed or for at most the grace period after stop. When a
+// monitor is provided it observes each result.
+func relayResults(actions <-chan result.NetconfResult, results chan<- result.NetconfResult, stop, clientsDone <-chan struct{}, gracePeriod time.Duration, monitor *abortMonitor) {
+	for {
+		select {
+		case r := <-actions:
+			if monitor != nil {
+				monitor.observe(&r, time.Now())
+			}
+			results <- r
+		case <-stop:
+			close(results)
+			expired := time.NewTimer(gracePeriod)
+			defer expired.Stop()
+			for {
+				select {
+				case <-actions:
+				case <-clientsDone:
+					return
+				case <-expired.C:
+					return
 				}
-			case "concurrent":
-				blockWg := sync.WaitGroup{}
-				for _, a := range block.Actions {
-					// do concurrently
-					blockWg.Add(1)
-					go func(a suite.Action) {
-						defer blockWg.Done()
-						action.Execute(start, cID, ts, a, resultChannel)
-					}(a)
+			}
+		}
+	}
+}
+
+// handleSignals cancels the run on the first signal, closing interrupted, and exits on the second
+func handleSignals(signals <-chan os.Signal, cancel context.CancelFunc, interrupted chan<- struct{}) {
+	sig, ok := <-signals
+	if !ok {
+		return
+	}
+	log.Printf("\n > Received %v, no new actions will be started, waiting up to %v for in-flight requests, interrupt again to exit immediately\n", sig, gracePeriodFlag)
+	close(interrupted)
+	cancel()
+	if sig, ok = <-signals; ok {
+		log.Printf("\n > Received %v, exiting immediately\n", sig)
+		exit(1)
+	}
+}
+
+// describeLoad summarises how the clients of a population execute its blocks
+func describeLoad(load *suite.Load) string {
+	if load.Pacing > 0 {
+		paced := *load
+		paced.Pacing = 0
+		return fmt.Sprintf("%v, iterations paced every %v", describeLoad(&paced), load.Pacing)
+	}
+	switch {
+	case len(load.Stages) > 0:
+		return fmt.Sprintf("%d stage load profile, lasting %v", len(load.Stages), stagesDuration(load.Stages))
+	case load.Rate != "" && load.Duration > 0:
+		return fmt.Sprintf("%d client(s), iterations started at %v, running for %v", load.Clients, load.Rate, load.Duration)
+	case load.Rate != "":
+		return fmt.Sprintf("%d client(s), %d iterations started at %v", load.Clients, load.Clients*load.Iterations, load.Rate)
+	case load.Duration > 0:
+		return fmt.Sprintf("%d client(s), running for %v, %d seconds wait between starting each client", load.Clients, load.Duration, load.Rampup)
+	default:
+		return fmt.Sprintf("%d client(s), %d iterations per client, %d seconds wait between starting each client", load.Clients, load.Iterations, load.Rampup)
+	}
+}
+
+// runPopulation starts the clients of a population according to its load, the clients are added to clientWg. Only
+// the clients in part are started, the load is otherwise followed as a whole so that the parts stay in step.
+func runPopulation(ctx context.Context, ts *suite.TestSuite, population *suite.Population, firstID int, start time.Time, part partition, misses *int64, clientWg *sync.WaitGroup, resultChannel chan result.NetconfResult) {
+	// newClient returns nil when the client belongs to another part
+	newClient := func(cID int) *action.Client {
+		if !part.owns(firstID + cID) {
+			return nil
+		}
+		client := action.NewClient(firstID+cID, start)
+		client.Population = population.Name
+		client.Misses = misses
+		client.Seed = ts.Seed
+		client.Counters = ts.Counters()
+		return client
+	}
+
+	if population.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		time.AfterFunc(population.Duration, cancel)
+	}
+
+	switch {
+	case population.IsOpenLoop():
+		// open loop, the clients are a pool that iterations are scheduled onto at a rate
+		var p *profile
+		if len(population.Stages) > 0 {
+			p = newProfile(population.Stages, time.Now())
+		}
+		arrivals := make(chan time.Time)
+		for cID := 0; cID < population.Clients; cID++ {
+			client := newClient(cID)
+			if client == nil {
+				continue
+			}
+			if p != nil {
+				client.Stage = &p.stage
+			}
+			clientWg.Add(1)
+			go handleArrivals(ctx, ts, population, client, arrivals, clientWg, resultChannel)
+		}
+		scheduleArrivals(ctx, &population.Load, p, part, arrivals)
+		close(arrivals)
+	case len(population.Stages) > 0:
+		followClientStages(ctx, ts, population, newClient, clientWg, resultChannel)
+	default:
+		// create concurrent sessions for each of the defined clients
+		for cID := 0; cID < population.Clients && ctx.Err() == nil; cID++ {
+			if client := newClient(cID); client != nil {
+				clientWg.Add(1)
+				go handleBlocks(ctx, ts, population, client, clientWg, resultChannel)
+			}
+			// handle rampup for each client
+			var waitDuration = float32(population.Rampup) / float32(population.Clients)
+			select {
+			case <-time.After(time.Duration(int(1000*waitDuration)) * time.Millisecond):
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+// scheduleArrivals hands iterations to the clients at the suite's rate, regardless of whether earlier iterations have
+// completed. Each arrival carries the time it was intended to start, so that a late start is recorded rather than
+// hidden by a slow device. When a load profile is defined the rate follows it, the rate is read again at least every
+// stageTick so that a ramp or a step is followed promptly rather than an interval at the old rate later.
+func scheduleArrivals(ctx context.Context, load *suite.Load, p *profile, part partition, arrivals chan<- time.Time) {
+	rate, _ := suite.ParseRate(load.Rate) // validated when the suite was loaded
+	due := time.Now()
+	// how much of the interval to the next arrival has elapsed, the first arrival is due straight away
+	progress := 1.0
+	for n := 0; load.IsTimed() || n < load.Clients*load.Iterations; {
+		if p != nil {
+			var ok bool
+			if rate, ok = p.at(due, rateTarget); !ok {
+				return
+			}
+		}
+		// nothing to schedule yet, or the next arrival is beyond the tick, check the load profile again shortly
+		step, arrive := stageTick, false
+		if rate > 0 {
+			remaining := time.Duration((1 - progress) * float64(time.Second) / rate)
+			if p == nil || remaining <= stageTick {
+				step, arrive = remaining, true
+			} else {
+				progress += step.Seconds() * rate
+			}
+		}
+		due = due.Add(step)
+		select {
+		case <-time.After(time.Until(due)):
+		case <-ctx.Done():
+			return
+		}
+		if !arrive {
+			continue
+		}
+		progress = 0
+		// the schedule is followed as a whole, only the arrivals in part are handed to the clients
+		if part.owns(n) {
+			select {
+			case arrivals <- due:
+			case <-ctx.Done():
+				return
+			}
+		}
+		n++
+	}
+}
+
+// handleArrivals executes an iteration of the blocks for each arrival, noting how late the iteration started
+func handleArrivals(ctx context.Context, ts *suite.TestSuite, population *suite.Population, client *action.Client, arrivals <-chan time.Time, clientWg *sync.WaitGroup, resultChannel chan result.NetconfResult) {
+	defer clientWg.Done()
+	// the client's YANG-push subscriptions end with the client, after its teardown
+	defer action.EndSubscriptions(client)
+	handlePhase(ts, client, "client-setup", population.GetBlocks("client-setup"), resultChannel)
+	defer handleClientTeardown(ctx, ts, population, client, resultChannel)
+	// the replies to the client's pipelined requests are received before its teardown
+	defer action.EndPipelines(client)
+	for due := range arrivals {
+		client.Lag = time.Since(due)
+		if !handleIteration(ctx, ts, population, client, resultChannel) {
+			return
+		}
+	}
+}
+
+// handleBlocks executes the iterations for a client, when the context is done no new actions are started. When
+// pacing is defined each iteration starts a pacing interval after the previous one started, an iteration that overruns
+// the interval is counted as a pacing miss and the next one starts straight away.
+func handleBlocks(ctx context.Context, ts *suite.TestSuite, population *suite.Population, client *action.Client, clientWg *sync.WaitGroup, resultChannel chan result.NetconfResult) {
+	defer clientWg.Done()
+	// the client's YANG-push subscriptions end with the client, after its teardown
+	defer action.EndSubscriptions(client)
+	handlePhase(ts, client, "client-setup", population.GetBlocks("client-setup"), resultChannel)
+	defer handleClientTeardown(ctx, ts, population, client, resultChannel)
+	// the replies to the client's pipelined requests are received before its teardown
+	defer action.EndPipelines(client)
+	next := time.Now()
+	for i := 0; population.IsTimed() || i < population.Iterations; i++ {
+		client.PacingMiss = false
+		if population.Pacing > 0 && i > 0 {
+			next = next.Add(population.Pacing)
+			if now := time.Now(); now.After(next) {
+				client.MissedPacing()
+				next = now
+			} else {
+				select {
+				case <-time.After(next.Sub(now)):
+				case <-ctx.Done():
+					return
 				}
-				blockWg.Wait()
-			case "init":
-				// do nothing
 			}
 		}
+		if !handleIteration(ctx, ts, population, client, resultChannel) {
+			return
+		}
+	}
+}
+
+// handlePhase sequentially executes the actions of the blocks for a setup or teardown phase, results are stamped with
+// the phase so that they are recorded separately from the measured load. A phase runs to completion even when the
+// context is done, so that anything set up is cleaned up.
+func handlePhase(ts *suite.TestSuite, client *action.Client, phase string, blocks []suite.Block, resultChannel chan result.NetconfResult) {
+	client.Phase = phase
+	defer func() { client.Phase = "" }()
+	for _, block := range blocks {
+		for idx := range block.Actions {
+			handleAction(context.Background(), ts, client, &block.Actions[idx], resultChannel)
+		}
+	}
+	// the phase completes once the replies to its pipelined requests have been received
+	action.EndPipelines(client)
+}
+
+// stoppedKey keys the channel, in the context of the load, that is closed when the run is interrupted or aborted rather
+// than ended by its duration or load profile
+type stoppedKey struct{}
+
+// handleClientTeardown executes the client-teardown blocks of a client, they are skipped when the run is interrupted
+// or aborted, as the suite's teardown blocks are, so that no new requests are sent once the run is stopped
+func handleClientTeardown(ctx context.Context, ts *suite.TestSuite, population *suite.Population, client *action.Client, resultChannel chan result.NetconfResult) {
+	if stopped, ok := ctx.Value(stoppedKey{}).(<-chan struct{}); ok {
+		select {
+		case <-stopped:
+			return
+		default:
+		}
 	}
-	clientWg.Done()
+	handlePhase(ts, client, "client-teardown", population.GetBlocks("client-teardown"), resultChannel)
+}
+
+// countActions returns the number of actions in the blocks
+func countActions(blocks []suite.Block) int {
+	var count int
+	for _, block := range blocks {
+		count += len(block.Actions)
+	}
+	return count
+}
+
+// handleIteration executes the blocks of a population once, the results of an iteration started in the warm-up are
+// marked. It returns false if the iteration was cut short because the context is done
+func handleIteration(ctx context.Context, ts *suite.TestSuite, population *suite.Population, client *action.Client, resultChannel chan result.NetconfResult) bool {
+	client.Warmup = ts.Warmup.Covers(client.Iteration, time.Since(client.Start))
+	client.Iteration++
+	for idx := range population.Blocks {
+		// block sections are executed sequentially
+		if !handleBlock(ctx, ts, client, &population.Blocks[idx], resultChannel) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleBlock determines the block type and processes the actions appropriately, blocks nested within the actions are
+// handled in turn. It returns false if the block was cut short because the context is done.
+func handleBlock(ctx context.Context, ts *suite.TestSuite, client *action.Client, block *suite.Block, resultChannel chan result.NetconfResult) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	// individual blocks may execute actions sequentially, councurrently, pick them at random or repeat them in a loop
+	switch block.Type {
+	case "sequential":
+		return handleActions(ctx, ts, client, block.Actions, resultChannel)
+	case "concurrent":
+		blockWg := sync.WaitGroup{}
+		// when parallelism is set, at most that many actions are in flight at once
+		var slots chan struct{}
+		if block.Parallelism > 0 {
+			slots = make(chan struct{}, block.Parallelism)
+		}
+		for idx := range block.Actions {
+			if slots != nil {
+				slots <- struct{}{}
+			}
+			// do concurrently
+			blockWg.Add(1)
+			go func(a *suite.Action) {
+				defer blockWg.Done()
+				handleAction(ctx, ts, client, a, resultChannel)
+				if slots != nil {
+					<-slots
+				}
+			}(&block.Actions[idx])
+		}
+		blockWg.Wait()
+	case "random":
+		r := client.Random(block, block.Seed)
+		for n := 0; n < block.Picks || n == 0; n++ {
+			a := block.Pick(r)
+			if !handleAction(ctx, ts, client, &a, resultChannel) {
+				return false
+			}
+		}
+	case "loop":
+		for n := 0; block.Count == 0 || n < block.Count; n++ {
+			if !handleActions(ctx, ts, client, block.Actions, resultChannel) {
+				return false
+			}
+			// the condition is checked against a reply, so the replies to any pipelined requests are waited for
+			if block.While != "" {
+				action.EndPipelines(client)
+			}
+			if !block.Repeats(client.LastReply()) {
+				break
+			}
+		}
+	case "init", "teardown", "client-setup", "client-teardown":
+		// do nothing, these run around the iterations
+	}
+	return ctx.Err() == nil
+}
+
+// handleActions executes the actions sequentially, it returns false if they were cut short because the context is done
+func handleActions(ctx context.Context, ts *suite.TestSuite, client *action.Client, actions []suite.Action, resultChannel chan result.NetconfResult) bool {
+	for idx := range actions {
+		if !handleAction(ctx, ts, client, &actions[idx], resultChannel) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleAction executes an action, or the block nested within it, it returns false if the context is done
+func handleAction(ctx context.Context, ts *suite.TestSuite, client *action.Client, a *suite.Action, resultChannel chan result.NetconfResult) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if a.Block != nil {
+		return handleBlock(ctx, ts, client, a.Block, resultChannel)
+	}
+	action.Execute(ctx, client, ts, *a, resultChannel)
+	return ctx.Err() == nil
 }
 
 func init() {
 	RootCmd.AddCommand(runCmd)
 	runCmd.PersistentFlags().BoolVarP(&diagFlag, "diag", "d", false, "Enable netconf diagnostics")
-
+	runCmd.PersistentFlags().DurationVar(&durationFlag, "duration", 0, "Run the blocks until the duration elapses (e.g. 8h), overrides iterations")
+	runCmd.PersistentFlags().StringSliceVar(&workersFlag, "workers", nil, "Split the clients across the workers listening at host:port, for e.g. host1:8300,host2:8300")
+	runCmd.PersistentFlags().StringVar(&workerTokenFlag, "worker-token", workerTokenFlag, "The token the workers accept jobs with, defaults to $"+tokenEnv)
+	runCmd.PersistentFlags().StringVar(&scenarioFlag, "scenario", "", "Run the named scenario of the Test Suite, for e.g. smoke, load or soak")
+	runCmd.PersistentFlags().Int64Var(&seedFlag, "seed", 0, "Seed the clients' random choices, to replay a run use the seed recorded in its results")
+	runCmd.PersistentFlags().BoolVar(&dryRunFlag, "dry-run", false, "Render the RPC of every action, as a run would send it, without connecting")
+	runCmd.PersistentFlags().DurationVar(&gracePeriodFlag, "grace-period", gracePeriodFlag, "How long to wait for in-flight requests after an interrupt before writing the results")
 }
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/result"
+	"github.com/damianoneill/nc-hammer/suite"
+	"gonum.org/v1/gonum/stat"
+)
+
+var (
+	// defaultAbortWindow and defaultAbortMinRequests apply when the abort rules do not set them
+	defaultAbortWindow      = 30 * time.Second
+	defaultAbortMinRequests = 10
+	// latencyCheckInterval limits how often the p99 latency is calculated, as the window has to be sorted
+	latencyCheckInterval = time.Second
+)
+
+// observation is a result of the measured load, as seen by the abort monitor
+type observation struct {
+	at      time.Time
+	err     bool
+	latency float64
+}
+
+// abortMonitor checks the abort rules against the results as they arrive, the reason is sent on aborted the first
+// time a rule is broken
+type abortMonitor struct {
+	rules              suite.Abort
+	window             []observation
+	errors             int
+	connectionFailures int
+	latencyChecked     time.Time
+	triggered          bool
+	aborted            chan string
+}
+
+func newAbortMonitor(rules *suite.Abort) *abortMonitor {
+	m := &abortMonitor{rules: *rules, aborted: make(chan string, 1)}
+	if m.rules.Window == 0 {
+		m.rules.Window = defaultAbortWindow
+	}
+	if m.rules.MinRequests == 0 {
+		m.rules.MinRequests = defaultAbortMinRequests
+	}
+	return m
+}
+
+// observe records a result and checks the rules, results of the setup and teardown phases and the notifications
+// received on subscriptions are not checked
+func (m *abortMonitor) observe(r *result.NetconfResult, now time.Time) {
+	if m.triggered || r.Phase != "" || r.IsNotification() {
+		return
+	}
+	if r.IsConnectionFailure() {
+		m.connectionFailures++
+	} else {
+		m.connectionFailures = 0
+	}
+	m.window = append(m.window, observation{at: now, err: r.Err != "", latency: r.Latency})
+	if r.Err != "" {
+		m.errors++
+	}
+	for len(m.window) > 0 && now.Sub(m.window[0].at) > m.rules.Window {
+		if m.window[0].err {
+			m.errors--
+		}
+		m.window = m.window[1:]
+	}
+	if reason := m.check(now); reason != "" {
+		m.triggered = true
+		m.aborted <- reason
+	}
+}
+
+// check returns the reason for aborting if a rule is broken
+func (m *abortMonitor) check(now time.Time) string {
+	if m.rules.ConnectionFailures > 0 && m.connectionFailures > m.rules.ConnectionFailures {
+		return fmt.Sprintf("%d consecutive connection failures", m.connectionFailures)
+	}
+	if len(m.window) < m.rules.MinRequests {
+		return ""
+	}
+	if rate := 100 * float64(m.errors) / float64(len(m.window)); m.rules.ErrorRate > 0 && rate > m.rules.ErrorRate {
+		return fmt.Sprintf("error rate %.2f%% over %v exceeded %v%%", rate, m.rules.Window, m.rules.ErrorRate)
+	}
+	if m.rules.P99Latency > 0 && now.Sub(m.latencyChecked) >= latencyCheckInterval {
+		m.latencyChecked = now
+		var latencies []float64
+		for idx := range m.window {
+			if !m.window[idx].err {
+				latencies = append(latencies, m.window[idx].latency)
+			}
+		}
+		if len(latencies) < m.rules.MinRequests {
+			return ""
+		}
+		sort.Float64s(latencies)
+		p99 := time.Duration(stat.Quantile(0.99, stat.Empirical, latencies, nil)) * time.Millisecond
+		if p99 > m.rules.P99Latency {
+			return fmt.Sprintf("p99 latency %v over %v exceeded %v", p99, m.rules.Window, m.rules.P99Latency)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/result"
+	"github.com/damianoneill/nc-hammer/suite"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_abortMonitor(t *testing.T) {
+	ok := result.NetconfResult{SessionID: 1, Latency: 100}
+	slow := result.NetconfResult{SessionID: 1, Latency: 3000}
+	failed := result.NetconfResult{SessionID: 1, Err: "rpc error"}
+	refused := result.NetconfResult{Err: "connection refused", ConnectionFailure: true}
+	teardown := result.NetconfResult{Err: "connection refused", ConnectionFailure: true, Phase: "teardown"}
+	// an error raised before a session is needed is not a connection failure
+	unestablished := result.NetconfResult{Err: "subscription stats has not been established by the client"}
+
+	tests := []struct {
+		name    string
+		rules   suite.Abort
+		results []result.NetconfResult
+		want    string
+	}{
+		{"consecutive connection failures", suite.Abort{ConnectionFailures: 3}, []result.NetconfResult{refused, refused, ok, refused, refused, refused, refused}, "4 consecutive connection failures"},
+		{"connection failures at the limit", suite.Abort{ConnectionFailures: 3}, []result.NetconfResult{refused, refused, refused}, ""},
+		{"connection failures interrupted", suite.Abort{ConnectionFailures: 3}, []result.NetconfResult{refused, refused, refused, ok, refused, refused}, ""},
+		{"errors before a session are not connection failures", suite.Abort{ConnectionFailures: 3}, []result.NetconfResult{unestablished, unestablished, unestablished}, ""},
+		{"phases are not checked", suite.Abort{ConnectionFailures: 1}, []result.NetconfResult{teardown, teardown}, ""},
+		{"error rate", suite.Abort{ErrorRate: 10, MinRequests: 5}, []result.NetconfResult{ok, ok, ok, failed, ok}, "error rate 20.00% over 30s exceeded 10%"},
+		{"error rate below min requests", suite.Abort{ErrorRate: 10, MinRequests: 5}, []result.NetconfResult{ok, ok, failed}, ""},
+		{"error rate within threshold", suite.Abort{ErrorRate: 25, MinRequests: 5}, []result.NetconfResult{ok, ok, ok, failed, ok}, ""},
+		{"p99 latency", suite.Abort{P99Latency: 2 * time.Second, MinRequests: 2}, []result.NetconfResult{ok, slow}, "p99 latency 3s over 30s exceeded 2s"},
+		{"p99 latency excludes errors", suite.Abort{P99Latency: 2 * time.Second, MinRequests: 2}, []result.NetconfResult{ok, ok, failed}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newAbortMonitor(&tt.rules)
+			now := time.Now()
+			for idx := range tt.results {
+				m.observe(&tt.results[idx], now)
+			}
+			select {
+			case reason := <-m.aborted:
+				assert.Equal(t, tt.want, reason)
+			default:
+				assert.Equal(t, tt.want, "")
+			}
+		})
+	}
+}
+
+func Test_abortMonitorWindow(t *testing.T) {
+	m := newAbortMonitor(&suite.Abort{ErrorRate: 50, Window: time.Second, MinRequests: 2})
+	failed := result.NetconfResult{SessionID: 1, Err: "rpc error"}
+	ok := result.NetconfResult{SessionID: 1, Latency: 100}
+	now := time.Now()
+
+	// the errors slide out of the window before enough requests are seen
+	m.observe(&failed, now)
+	m.observe(&ok, now.Add(2*time.Second))
+	m.observe(&ok, now.Add(2*time.Second))
+	assert.Len(t, m.aborted, 0)
+	assert.Len(t, m.window, 2)
+
+	// only the first broken rule is reported
+	m.observe(&failed, now.Add(2*time.Second))
+	m.observe(&failed, now.Add(2*time.Second))
+	m.observe(&failed, now.Add(2*time.Second))
+	assert.Equal(t, "error rate 60.00% over 1s exceeded 50%", <-m.aborted)
+	assert.Len(t, m.aborted, 0)
+}
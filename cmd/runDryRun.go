@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/action"
+	"github.com/damianoneill/nc-hammer/suite"
+	"github.com/damianoneill/net/netconf"
+)
+
+// dryRun walks a Test Suite as the runner would, rendering the RPC of every NETCONF action rather than sending it
+type dryRun struct {
+	ts       *suite.TestSuite
+	out      io.Writer
+	sessions map[string]int // the requests sent on each reused session, keyed by client and host
+	rpcs     int
+	invalid  int
+	// the ids standing in for those of the YANG-push subscriptions, by name and last assigned, keyed by client and host
+	subscriptions   map[string]map[string]string
+	subscriptionIDs map[string]int
+}
+
+// runDryRun writes the RPCs a run of the Test Suite would send, along with the target host and whether a session is
+// established or reused, no connections are made. An error is returned if any of the RPCs could not be rendered.
+func runDryRun(ts *suite.TestSuite, out io.Writer) error {
+	d := &dryRun{ts: ts, out: out, sessions: make(map[string]int), subscriptions: make(map[string]map[string]string), subscriptionIDs: make(map[string]int)}
+	start := time.Now()
+	d.phase(d.client(0, start), "init", ts.GetBlocks("init"))
+	populations := ts.GetPopulations()
+	var firstID int
+	for idx := range populations {
+		population := &populations[idx]
+		iterations := population.Iterations
+		if population.IsTimed() {
+			// a timed load repeats the iterations until the run ends, a single iteration shows what is repeated
+			iterations = 1
+			d.comment("population %v is timed, a single iteration is shown for each client", population.Name)
+		}
+		for cID := 0; cID < population.MaxClients(); cID++ {
+			client := d.client(firstID+cID, start)
+			client.Population = population.Name
+			d.phase(client, "client-setup", population.GetBlocks("client-setup"))
+			for i := 0; i < iterations; i++ {
+				client.Iteration = i + 1
+				d.comment("client %d iteration %d", client.ID, i+1)
+				for bIdx := range population.Blocks {
+					d.block(client, &population.Blocks[bIdx])
+				}
+			}
+			d.phase(client, "client-teardown", population.GetBlocks("client-teardown"))
+		}
+		// the ids of the next population follow those of any clients added over the stages, as in a run
+		firstID += population.ClientIDs()
+	}
+	d.phase(d.client(0, start), "teardown", ts.GetBlocks("teardown"))
+	if d.invalid > 0 {
+		return fmt.Errorf("%d of %d RPCs could not be rendered", d.invalid, d.rpcs)
+	}
+	return nil
+}
+
+// client returns a client that makes the same random choices as in a run with the suite's seed
+func (d *dryRun) client(cID int, start time.Time) *action.Client {
+	client := action.NewClient(cID, start)
+	client.Seed = d.ts.Seed
+	client.Counters = d.ts.Counters()
+	return client
+}
+
+func (d *dryRun) comment(format string, a ...interface{}) {
+	fmt.Fprintf(d.out, "<!-- "+format+" -->\n", a...)
+}
+
+func (d *dryRun) phase(client *action.Client, phase string, blocks []suite.Block) {
+	if len(blocks) == 0 {
+		return
+	}
+	d.comment("client %d %v", client.ID, phase)
+	for _, block := range blocks {
+		for idx := range block.Actions {
+			d.action(client, &block.Actions[idx])
+		}
+	}
+}
+
+// block renders the actions of a block in the order the runner would execute them, concurrent actions are rendered
+// in the order they are defined
+func (d *dryRun) block(client *action.Client, block *suite.Block) {
+	switch block.Type {
+	case "sequential", "concurrent":
+		d.actions(client, block.Actions)
+	case "random":
+		r := client.Random(block, block.Seed)
+		for n := 0; n < block.Picks || n == 0; n++ {
+			a := block.Pick(r)
+			d.action(client, &a)
+		}
+	case "loop":
+		if block.While != "" {
+			// the replies decide how often the loop repeats, a single pass is shown
+			d.comment("loop repeats, up to %d times, while the reply matches %v", block.Count, block.While)
+			d.actions(client, block.Actions)
+			return
+		}
+		for n := 0; n < block.Count; n++ {
+			d.actions(client, block.Actions)
+		}
+	}
+}
+
+func (d *dryRun) actions(client *action.Client, actions []suite.Action) {
+	for idx := range actions {
+		d.action(client, &actions[idx])
+	}
+}
+
+func (d *dryRun) action(client *action.Client, a *suite.Action) {
+	switch {
+	case a.Block != nil:
+		d.block(client, a.Block)
+	case a.Sleep != nil:
+		d.comment("sleep %v", a.Sleep.Period(client.Random(a.Sleep, a.Sleep.Seed)))
+	case a.Netconf != nil:
+		kind := "netconf"
+		if config := d.ts.GetConfig(a.Netconf.Hostname); config != nil && a.Netconf.GetPipeline(config) > 0 {
+			kind = "pipelined"
+			d.comment("pipelined, the client keeps up to %d requests in flight on its session", a.Netconf.GetPipeline(config))
+		}
+		d.rpc(client, a.Netconf.Hostname, func() (string, error) { return d.netconf(client, a.Netconf) }, kind)
+	case a.Subscribe != nil:
+		d.rpc(client, a.Subscribe.Hostname, a.Subscribe.ToXMLString, "subscribe")
+	case a.YangPush != nil:
+		d.rpc(client, a.YangPush.Hostname, func() (string, error) { return d.yangPush(client, a.YangPush) }, "yang-push")
+	}
+}
+
+// netconf renders a netconf action, its templates are expanded as the client would when executing it
+func (d *dryRun) netconf(client *action.Client, n *suite.Netconf) (string, error) {
+	expanded, err := n.Expand(client.Vars(n.Hostname))
+	if err != nil {
+		return "", err
+	}
+	return expanded.ToXMLString()
+}
+
+// yangPush renders a YANG-push operation, the subscription ids the host would assign are stood in for by numbering
+// each client's subscriptions to a host
+func (d *dryRun) yangPush(client *action.Client, push *suite.YangPush) (string, error) {
+	key := strconv.Itoa(client.ID) + push.Hostname
+	if d.subscriptions[key] == nil {
+		d.subscriptions[key] = make(map[string]string)
+	}
+	ids := d.subscriptions[key]
+	if push.Operation == "establish-subscription" {
+		d.subscriptionIDs[key]++
+		if push.Name != "" {
+			ids[push.Name] = strconv.Itoa(d.subscriptionIDs[key])
+		}
+		return push.ToXMLString("")
+	}
+	id, present := ids[push.Name]
+	if !present {
+		return "", errors.New("subscription " + push.Name + " has not been established by the client")
+	}
+	if push.Operation == "delete-subscription" {
+		delete(ids, push.Name)
+	}
+	return push.ToXMLString(id)
+}
+
+// rpc renders the request as it is framed within the rpc element, message ids are numbered per session. A
+// subscription always has a session of its own, a client's YANG-push subscriptions to a host share one, pipelined
+// requests are always sent on the client's reused session.
+func (d *dryRun) rpc(client *action.Client, hostname string, render func() (string, error), kind string) {
+	d.rpcs++
+	config := d.ts.GetConfig(hostname)
+	if config == nil {
+		d.invalid++
+		d.comment("error: no config for host %v", hostname)
+		return
+	}
+	body, err := render()
+	if err != nil {
+		d.invalid++
+		d.comment("error: %v", err)
+		return
+	}
+	host := config.Hostname + ":" + strconv.Itoa(config.Port)
+	session := "new session, closed after the request"
+	messageID := 1
+	switch {
+	case kind == "subscribe":
+		session = "new session, held open by the subscription"
+	case kind == "yang-push":
+		key := "yang-push" + strconv.Itoa(client.ID) + host
+		d.sessions[key]++
+		messageID = d.sessions[key]
+		session = "the client's YANG-push session"
+		if messageID == 1 {
+			session = "new session, held by the client for its YANG-push subscriptions"
+		}
+	case config.Reuseconnection || kind == "pipelined":
+		key := strconv.Itoa(client.ID) + host
+		d.sessions[key]++
+		messageID = d.sessions[key]
+		session = "reused session"
+		if messageID == 1 {
+			session = "new session, reused by the client's later requests"
+		}
+	}
+	d.comment("%v, client %d, %v", host, client.ID, session)
+	// nolint
+	framed, _ := xml.Marshal(&netconf.RPCMessage{MessageID: strconv.Itoa(messageID), Methods: []byte(body)})
+	fmt.Fprintf(d.out, "%s\n", framed)
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/suite"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_runDryRun(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/phases.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	var out bytes.Buffer
+	assert.NoError(t, runDryRun(ts, &out))
+
+	// init, then client-setup, 2 iterations and client-teardown for each of the 2 clients, then teardown
+	assert.Equal(t, 10, strings.Count(out.String(), "<rpc "))
+	assert.Equal(t, 4, strings.Count(out.String(), `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><get/></rpc>`))
+	assert.Contains(t, out.String(), "<!-- client 1 iteration 2 -->")
+	assert.Contains(t, out.String(), "<!-- 00.00.00.00:830, client 1, new session, closed after the request -->")
+
+	// reused sessions number their requests, rendering problems are reported rather than panicking
+	ts.Configs[0].Reuseconnection = true
+	bad := "<top"
+	ts.Blocks[1].Actions[0].Netconf.Config = &bad
+	out.Reset()
+	assert.EqualError(t, runDryRun(ts, &out), "2 of 10 RPCs could not be rendered")
+	assert.Contains(t, out.String(), "<!-- error: config data is not valid xml -->")
+	assert.Contains(t, out.String(), "<!-- 00.00.00.00:830, client 1, reused session -->")
+	assert.Contains(t, out.String(), `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="3"><edit-config>`)
+}
+
+func Test_runDryRunSeed(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/random.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	// the block leaves its seed to the suite
+	ts.Blocks[0].Seed = 0
+	render := func(seed int64) string {
+		ts.Seed = seed
+		var out bytes.Buffer
+		assert.NoError(t, runDryRun(ts, &out))
+		return out.String()
+	}
+	assert.Equal(t, render(7), render(7), "the same seed should replay the same picks")
+	assert.NotEqual(t, render(7), render(8))
+}
+
+func Test_runDryRunSubscribe(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/phases.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	ts.Configs[0].Reuseconnection = true
+	ts.Blocks = []suite.Block{{Type: "sequential", Actions: []suite.Action{{Subscribe: &suite.Subscribe{Hostname: ts.Configs[0].Hostname, Duration: time.Minute}}}}}
+	ts.Clients, ts.Iterations = 1, 1
+	var out bytes.Buffer
+	assert.NoError(t, runDryRun(ts, &out))
+
+	// a subscription has a session of its own, even when the host's sessions are reused
+	assert.Contains(t, out.String(), "<!-- 00.00.00.00:830, client 0, new session, held open by the subscription -->")
+	assert.Contains(t, out.String(), `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><create-subscription xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"/></rpc>`)
+}
+
+func Test_runDryRunYangPush(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/phases.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	host := ts.Configs[0].Hostname
+	filter := &suite.Filter{Type: "xpath", Select: "/interfaces"}
+	ts.Blocks = []suite.Block{{Type: "sequential", Actions: []suite.Action{
+		{YangPush: &suite.YangPush{Hostname: host, Operation: "establish-subscription", Name: "stats", Filter: filter, Period: time.Second}},
+		{YangPush: &suite.YangPush{Hostname: host, Operation: "establish-subscription", Name: "changes", Filter: filter, OnChange: true}},
+		{YangPush: &suite.YangPush{Hostname: host, Operation: "delete-subscription", Name: "changes"}},
+		{YangPush: &suite.YangPush{Hostname: host, Operation: "delete-subscription", Name: "changes"}},
+	}}}
+	ts.Clients, ts.Iterations = 1, 1
+	var out bytes.Buffer
+	assert.EqualError(t, runDryRun(ts, &out), "1 of 4 RPCs could not be rendered")
+
+	// the client's subscriptions share a session, the ids the host would assign are numbered
+	assert.Contains(t, out.String(), "<!-- 00.00.00.00:830, client 0, new session, held by the client for its YANG-push subscriptions -->")
+	assert.Contains(t, out.String(), "<!-- 00.00.00.00:830, client 0, the client's YANG-push session -->")
+	assert.Contains(t, out.String(), `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="3"><delete-subscription xmlns="urn:ietf:params:xml:ns:yang:ietf-subscribed-notifications"><id>2</id></delete-subscription></rpc>`)
+	assert.Contains(t, out.String(), "<!-- error: subscription changes has not been established by the client -->")
+}
+
+func Test_runDryRunPipelined(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/phases.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	host := ts.Configs[0].Hostname
+	operation := "get"
+	ts.Blocks = []suite.Block{{Type: "sequential", Actions: []suite.Action{
+		{Netconf: &suite.Netconf{Hostname: host, Operation: &operation, Pipeline: 8}},
+		{Netconf: &suite.Netconf{Hostname: host, Operation: &operation, Pipeline: 8}},
+	}}}
+	ts.Clients, ts.Iterations = 1, 1
+	var out bytes.Buffer
+	assert.NoError(t, runDryRun(ts, &out))
+
+	// pipelined requests share the client's session, even when the host's sessions are not reused
+	assert.Contains(t, out.String(), "<!-- pipelined, the client keeps up to 8 requests in flight on its session -->")
+	assert.Contains(t, out.String(), "<!-- 00.00.00.00:830, client 0, new session, reused by the client's later requests -->")
+	assert.Contains(t, out.String(), "<!-- 00.00.00.00:830, client 0, reused session -->")
+}
+
+func Test_runDryRunTemplates(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/phases.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	host := ts.Configs[0].Hostname
+	operation := "get"
+	ts.Blocks = []suite.Block{{Type: "sequential", Actions: []suite.Action{
+		{Netconf: &suite.Netconf{Hostname: host, Operation: &operation, Filter: &suite.Filter{Type: "xpath", Select: "/users/user[name='user-{{.Client}}-{{.Iteration}}']"}}},
+	}}}
+	ts.Clients, ts.Iterations = 2, 2
+	var out bytes.Buffer
+	assert.NoError(t, runDryRun(ts, &out))
+
+	// the templates are rendered for each client and iteration
+	for _, name := range []string{"user-0-1", "user-0-2", "user-1-1", "user-1-2"} {
+		assert.Contains(t, out.String(), `select="/users/user[name=&apos;`+name+`&apos;]"`)
+	}
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/action"
+	"github.com/damianoneill/nc-hammer/result"
+	"github.com/damianoneill/nc-hammer/suite"
+)
+
+// stageTick is how often the load profile is re-evaluated while following it
+var stageTick = 10 * time.Millisecond
+
+// profile follows the load profile defined by the stages of a Test Suite
+type profile struct {
+	stages []suite.Stage
+	start  time.Time
+	stage  int32 // the active stage numbered from 1, shared with the clients so their results can be stamped
+}
+
+func newProfile(stages []suite.Stage, start time.Time) *profile {
+	return &profile{stages: stages, start: start}
+}
+
+// at returns the target of the load profile at time t, where target returns the value a stage ramps to. The active
+// stage is recorded, ok is false once every stage has completed.
+func (p *profile) at(t time.Time, target func(suite.Stage) float64) (value float64, ok bool) {
+	elapsed := t.Sub(p.start)
+	var previous float64
+	for idx, stage := range p.stages {
+		next := target(stage)
+		if elapsed < stage.Ramp+stage.Duration {
+			atomic.StoreInt32(&p.stage, int32(idx+1))
+			if elapsed < stage.Ramp {
+				return previous + (next-previous)*float64(elapsed)/float64(stage.Ramp), true
+			}
+			return next, true
+		}
+		elapsed -= stage.Ramp + stage.Duration
+		previous = next
+	}
+	return previous, false
+}
+
+// stagesDuration returns how long a load profile lasts
+func stagesDuration(stages []suite.Stage) time.Duration {
+	var total time.Duration
+	for _, stage := range stages {
+		total += stage.Ramp + stage.Duration
+	}
+	return total
+}
+
+// describeStage summarises a stage of a load profile
+func describeStage(stage suite.Stage) string {
+	target := fmt.Sprintf("%d client(s)", stage.Clients)
+	if stage.Rate != "" {
+		target = stage.Rate
+	}
+	description := fmt.Sprintf("%v ramped over %v, held for %v", target, stage.Ramp, stage.Duration)
+	if stage.Name != "" {
+		description = stage.Name + ", " + description
+	}
+	return description
+}
+
+// clientTarget is the number of clients a closed loop stage ramps to
+func clientTarget(stage suite.Stage) float64 {
+	return float64(stage.Clients)
+}
+
+// rateTarget is the rate, in iterations per second, an open loop stage ramps to
+func rateTarget(stage suite.Stage) float64 {
+	rate, _ := suite.ParseRate(stage.Rate) // validated when the suite was loaded
+	return rate
+}
+
+// followClientStages adds and retires clients to track the load profile, retired clients complete any in-flight
+// actions but start no new ones. Each client added is given a fresh id, numbered from 0 in the order the clients are
+// added, newClient returns nil for a client that is not started by this process
+func followClientStages(ctx context.Context, ts *suite.TestSuite, population *suite.Population, newClient func(int) *action.Client, clientWg *sync.WaitGroup, resultChannel chan result.NetconfResult) {
+	p := newProfile(population.Stages, time.Now())
+	var retire []context.CancelFunc
+	var added int
+	defer func() {
+		for _, cancel := range retire {
+			cancel()
+		}
+	}()
+	ticker := time.NewTicker(stageTick)
+	defer ticker.Stop()
+	for {
+		target, ok := p.at(time.Now(), clientTarget)
+		if !ok {
+			break
+		}
+		for len(retire) < int(target) {
+			clientCtx, cancel := context.WithCancel(ctx)
+			// a client that belongs to another part is tracked, but not started
+			if client := newClient(added); client != nil {
+				client.Stage = &p.stage
+				clientWg.Add(1)
+				go handleBlocks(clientCtx, ts, population, client, clientWg, resultChannel)
+			}
+			retire = append(retire, cancel)
+			added++
+		}
+		for len(retire) > int(target) {
+			retire[len(retire)-1]()
+			retire = retire[:len(retire)-1]
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/result"
+	"github.com/damianoneill/nc-hammer/suite"
+)
+
+// partition identifies the share of the clients, and of the open loop arrivals, that a process executes
+type partition struct {
+	index int
+	of    int
+}
+
+// wholeRun is the partition of a run that is not split across workers
+var wholeRun = partition{index: 0, of: 1}
+
+// owns returns true if client or arrival n belongs to the partition
+func (p partition) owns(n int) bool {
+	return n%p.of == p.index
+}
+
+// message is exchanged, as a stream of JSON, between the controller and a worker. The controller sends a job, the
+// worker replies when ready, the controller then starts the worker and may later stop it. The worker streams its
+// results and finally signals that it is done.
+type message struct {
+	Job    *job                  `json:"job,omitempty"`
+	Ready  bool                  `json:"ready,omitempty"`
+	Start  bool                  `json:"start,omitempty"`
+	Offset time.Duration         `json:"offset,omitempty"` // with start, how long the controller's run has been going
+	Stop   bool                  `json:"stop,omitempty"`
+	Result *result.NetconfResult `json:"result,omitempty"`
+	Done   bool                  `json:"done,omitempty"`
+	Misses int64                 `json:"misses,omitempty"` // with done, the iterations that overran their pacing
+	Err    string                `json:"err,omitempty"`
+}
+
+// job is the share of a Test Suite run that a worker executes
+type job struct {
+	Suite       *suite.TestSuite `json:"suite"`
+	Worker      int              `json:"worker"`
+	Workers     int              `json:"workers"`
+	Diag        bool             `json:"diag"`
+	GracePeriod time.Duration    `json:"grace-period"`
+	Token       string           `json:"token"` // authorises the job, it must match the worker's token
+}
+
+// worker is the controller's connection to a worker
+type worker struct {
+	address string
+	conn    net.Conn
+	enc     *json.Encoder
+	dec     *json.Decoder
+}
+
+// workerDialTimeout limits how long the controller waits to connect to a worker
+var workerDialTimeout = 10 * time.Second
+
+// prepareWorkers connects to the workers and hands each of them its job, it returns once every worker is ready
+func prepareWorkers(ts *suite.TestSuite, addresses []string) ([]*worker, error) {
+	for _, population := range ts.GetPopulations() {
+		if population.IsOpenLoop() && population.Clients < len(addresses) {
+			return nil, errors.New("an open loop load needs at least as many clients as workers, each worker needs clients to execute its share of the iterations")
+		}
+	}
+	for _, config := range ts.Configs {
+		if config.Maxoutstanding > 0 && config.Maxoutstanding < len(addresses) {
+			return nil, errors.New("maxoutstanding of host " + config.Hostname + " is split across the workers, it should be at least the number of workers")
+		}
+	}
+	var workers []*worker
+	for idx, address := range addresses {
+		conn, err := net.DialTimeout("tcp", address, workerDialTimeout)
+		if err != nil {
+			closeWorkers(workers)
+			return nil, err
+		}
+		w := &worker{address: address, conn: conn, enc: json.NewEncoder(conn), dec: json.NewDecoder(conn)}
+		workers = append(workers, w)
+		j := &job{Suite: workerShare(ts, idx, len(addresses)), Worker: idx, Workers: len(addresses), Diag: diagFlag, GracePeriod: gracePeriodFlag, Token: workerTokenFlag}
+		if err = w.enc.Encode(message{Job: j}); err != nil {
+			closeWorkers(workers)
+			return nil, err
+		}
+	}
+	for _, w := range workers {
+		var m message
+		if err := w.dec.Decode(&m); err != nil || !m.Ready {
+			closeWorkers(workers)
+			if err == nil {
+				err = errors.New(m.Err)
+			}
+			return nil, errors.New("worker " + w.address + ": " + err.Error())
+		}
+	}
+	return workers, nil
+}
+
+// startWorkers starts the workers together and relays the results they stream back, when the context is done the
+// workers are stopped. The workers are sent the time since start, so that their results are timed from the start of
+// the run rather than their own. The returned channel is closed once every worker is done, their pacing misses are
+// added to misses.
+func startWorkers(ctx context.Context, workers []*worker, start time.Time, misses *int64, resultChannel chan result.NetconfResult) <-chan struct{} {
+	workerWg := sync.WaitGroup{}
+	for _, w := range workers {
+		// nolint
+		w.enc.Encode(message{Start: true, Offset: time.Since(start)})
+	}
+	for _, w := range workers {
+		workerWg.Add(1)
+		go func(w *worker) {
+			defer workerWg.Done()
+			for {
+				var m message
+				if err := w.dec.Decode(&m); err != nil {
+					log.Printf("\n > Worker %v stopped unexpectedly: %v\n", w.address, err)
+					return
+				}
+				if m.Result != nil {
+					resultChannel <- *m.Result
+				}
+				if m.Done {
+					atomic.AddInt64(misses, m.Misses)
+					return
+				}
+			}
+		}(w)
+	}
+	finished := make(chan struct{})
+	go func() {
+		workerWg.Wait()
+		closeWorkers(workers)
+		close(finished)
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			for _, w := range workers {
+				// nolint
+				w.enc.Encode(message{Stop: true})
+			}
+		case <-finished:
+		}
+	}()
+	return finished
+}
+
+// workerShare returns the Test Suite the worker executes, the hosts' caps on the requests in flight are split across
+// the workers, the remainder going to the lowest indexes, so that each cap holds for the run as a whole
+func workerShare(ts *suite.TestSuite, worker, workers int) *suite.TestSuite {
+	share := *ts
+	share.Configs = make(suite.Configs, len(ts.Configs))
+	copy(share.Configs, ts.Configs)
+	for idx := range share.Configs {
+		config := &share.Configs[idx]
+		if config.Maxoutstanding <= 0 {
+			continue
+		}
+		remainder := config.Maxoutstanding % workers
+		config.Maxoutstanding /= workers
+		if worker < remainder {
+			config.Maxoutstanding++
+		}
+	}
+	return &share
+}
+
+func closeWorkers(workers []*worker) {
+	for _, w := range workers {
+		// nolint
+		w.conn.Close()
+	}
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/result"
+	"github.com/damianoneill/nc-hammer/suite"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_partitionOwns(t *testing.T) {
+	assert.True(t, wholeRun.owns(0))
+	assert.True(t, wholeRun.owns(7))
+	second := partition{index: 1, of: 3}
+	var owned []int
+	for n := 0; n < 9; n++ {
+		if second.owns(n) {
+			owned = append(owned, n)
+		}
+	}
+	assert.Equal(t, []int{1, 4, 7}, owned)
+}
+
+// startTestWorkers starts n workers listening on localhost, returning their addresses, the controller is given the
+// workers' token
+func startTestWorkers(t *testing.T, n int) []string {
+	workerTokenFlag = "test-token"
+	var addresses []string
+	for i := 0; i < n; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Problem listening on localhost: %v", err)
+		}
+		go serveWorker(listener, "test-token") // nolint
+		addresses = append(addresses, listener.Addr().String())
+	}
+	return addresses
+}
+
+func Test_runTestSuiteWorkers(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/phases.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	ts.Clients = 3
+	workersFlag = startTestWorkers(t, 2)
+	defer func() { workersFlag = nil }()
+	var buff bytes.Buffer
+	log.SetOutput(&buff)
+	rescueStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+
+	runTestSuite(ts)
+
+	w.Close()
+	os.Stdout = rescueStdout
+
+	assert.Contains(t, buff.String(), " > clients split across 2 worker(s)")
+	assert.Contains(t, buff.String(), "executing share 1 of 2 of the clients")
+	assert.Contains(t, buff.String(), "executing share 2 of 2 of the clients")
+
+	// the workers' results are merged into one results directory, with the init and teardown run by the controller
+	dirs, _ := ioutil.ReadDir("results")
+	results, _, err := result.UnarchiveResults(filepath.Join("results", dirs[len(dirs)-1].Name()))
+	if err != nil {
+		t.Fatalf("Problem loading results: %v", err)
+	}
+	clients := map[int]int{}
+	phases := map[string]int{}
+	for idx := range results {
+		phases[results[idx].Phase]++
+		if results[idx].Phase == "" {
+			clients[results[idx].Client]++
+		}
+	}
+	assert.Equal(t, map[int]int{0: 2, 1: 2, 2: 2}, clients)
+	assert.Equal(t, map[string]int{"init": 1, "client-setup": 3, "": 6, "client-teardown": 3, "teardown": 1}, phases)
+	// clean up test files
+	os.RemoveAll("results")
+}
+
+func Test_runTestSuiteWorkersAbort(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/duration.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	ts.Duration = time.Hour
+	ts.Abort = &suite.Abort{ConnectionFailures: 5}
+	workersFlag = startTestWorkers(t, 2)
+	defer func() { workersFlag = nil }()
+	var buff bytes.Buffer
+	log.SetOutput(&buff)
+	rescueStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+
+	start := time.Now()
+	runTestSuite(ts)
+	elapsed := time.Since(start)
+
+	w.Close()
+	os.Stdout = rescueStdout
+
+	// the controller stops the workers when the run is aborted
+	assert.True(t, elapsed < 2*time.Second, "run should stop shortly after the abort")
+	assert.Equal(t, "abort", ts.Outcome.Ended)
+	assert.NotContains(t, buff.String(), "stopped unexpectedly")
+	// clean up test files
+	os.RemoveAll("results")
+}
+
+func Test_prepareWorkersOpenLoop(t *testing.T) {
+	ts := &suite.TestSuite{Load: suite.Load{Clients: 1, Iterations: 1, Rate: "10/s"}}
+	_, err := prepareWorkers(ts, []string{"127.0.0.1:1", "127.0.0.1:2"})
+	assert.EqualError(t, err, "an open loop load needs at least as many clients as workers, each worker needs clients to execute its share of the iterations")
+}
+
+func Test_prepareWorkersToken(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/phases.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	addresses := startTestWorkers(t, 1)
+	var buff bytes.Buffer
+	log.SetOutput(&buff)
+	defer func() { workerTokenFlag = "" }()
+
+	for _, token := range []string{"", "wrong-token"} {
+		workerTokenFlag = token
+		_, err = prepareWorkers(ts, addresses)
+		assert.EqualError(t, err, "worker "+addresses[0]+": job refused, the token does not match the worker's")
+	}
+	assert.Contains(t, buff.String(), "refused, the token does not match")
+}
+
+func Test_startWorkersOffset(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/phases.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	ts.Warmup = &suite.Warmup{Duration: 30 * time.Minute}
+	workers, err := prepareWorkers(ts, startTestWorkers(t, 1))
+	if err != nil {
+		t.Fatalf("Problem preparing workers: %v", err)
+	}
+	var buff bytes.Buffer
+	log.SetOutput(&buff)
+	rescueStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+
+	// the controller's run started an hour ago, so the worker's clients are past the warm-up
+	var misses int64
+	results := make(chan result.NetconfResult, 100)
+	<-startWorkers(context.Background(), workers, time.Now().Add(-time.Hour), &misses, results)
+	close(results)
+
+	w.Close()
+	os.Stdout = rescueStdout
+
+	var measured int
+	for r := range results {
+		assert.False(t, r.Warmup, "the warm-up should be timed from the controller's start")
+		if r.Phase == "" {
+			measured++
+		}
+	}
+	assert.Equal(t, 4, measured)
+}
+
+func Test_serveWorkerSilentConnection(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/phases.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	addresses := startTestWorkers(t, 1)
+	var buff bytes.Buffer
+	log.SetOutput(&buff)
+
+	// a connection that sends nothing does not hold up a controller's job
+	silent, err := net.Dial("tcp", addresses[0])
+	if err != nil {
+		t.Fatalf("Problem connecting to the worker: %v", err)
+	}
+	defer silent.Close()
+	workers, err := prepareWorkers(ts, addresses)
+	if err != nil {
+		t.Fatalf("Problem preparing workers: %v", err)
+	}
+	defer closeWorkers(workers)
+
+	// but a second job is refused while the first is held
+	_, err = prepareWorkers(ts, addresses)
+	assert.EqualError(t, err, "worker "+addresses[0]+": job refused, the worker is busy with another job")
+}
+
+func Test_workerShare(t *testing.T) {
+	ts := &suite.TestSuite{Configs: suite.Configs{{Hostname: "10.0.0.1", Maxoutstanding: 20}, {Hostname: "10.0.0.2"}}}
+	var caps []int
+	for idx := 0; idx < 3; idx++ {
+		share := workerShare(ts, idx, 3)
+		caps = append(caps, share.Configs[0].Maxoutstanding)
+		assert.Equal(t, 0, share.Configs[1].Maxoutstanding, "a host without a cap stays uncapped")
+	}
+	// the cap holds across the workers, the remainder going to the lowest indexes
+	assert.Equal(t, []int{7, 7, 6}, caps)
+	assert.Equal(t, 20, ts.Configs[0].Maxoutstanding, "the suite itself is unchanged")
+
+	_, err := prepareWorkers(&suite.TestSuite{Configs: suite.Configs{{Hostname: "10.0.0.1", Maxoutstanding: 1}}}, []string{"127.0.0.1:1", "127.0.0.1:2"})
+	assert.EqualError(t, err, "maxoutstanding of host 10.0.0.1 is split across the workers, it should be at least the number of workers")
+}
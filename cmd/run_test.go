@@ -2,17 +2,24 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/damianoneill/nc-hammer/action"
+	"github.com/damianoneill/nc-hammer/result"
 	"github.com/damianoneill/nc-hammer/suite"
+	"github.com/damianoneill/net/netconf"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -113,3 +120,546 @@ func Test_runTestSuite(t *testing.T) {
 	os.RemoveAll("results")
 
 }
+
+func Test_runTestSuiteDuration(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/duration.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	var buff bytes.Buffer
+	log.SetOutput(&buff)
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	start := time.Now()
+	runTestSuite(ts)
+	elapsed := time.Since(start)
+
+	w.Close()
+	r.Close()
+	os.Stdout = rescueStdout
+
+	assert.True(t, elapsed >= ts.Duration, "run should last at least the duration")
+	assert.True(t, elapsed < ts.Duration+2*time.Second, "run should stop shortly after the duration")
+	assert.Equal(t, "duration", ts.Outcome.Ended)
+	assert.Contains(t, buff.String(), "running for 300ms")
+	// clean up test files
+	os.RemoveAll("results")
+}
+
+func Test_scheduleArrivals(t *testing.T) {
+	load := &suite.Load{Clients: 2, Iterations: 3, Rate: "100/s"}
+	arrivals := make(chan time.Time)
+	go func() {
+		scheduleArrivals(context.Background(), load, nil, wholeRun, arrivals)
+		close(arrivals)
+	}()
+
+	var due []time.Time
+	for d := range arrivals {
+		// a slow client should not slow down the schedule
+		time.Sleep(15 * time.Millisecond)
+		due = append(due, d)
+	}
+	assert.Len(t, due, load.Clients*load.Iterations)
+	for idx := 1; idx < len(due); idx++ {
+		assert.Equal(t, 10*time.Millisecond, due[idx].Sub(due[idx-1]), "arrivals should be scheduled at the rate")
+	}
+}
+
+func Test_scheduleArrivalsRamp(t *testing.T) {
+	load := &suite.Load{Clients: 1, Stages: []suite.Stage{{Ramp: 500 * time.Millisecond, Rate: "400/s"}, {Duration: 100 * time.Millisecond, Rate: "400/s"}}}
+	p := newProfile(load.Stages, time.Now())
+	arrivals := make(chan time.Time, 1000)
+	scheduleArrivals(context.Background(), load, p, wholeRun, arrivals)
+	close(arrivals)
+
+	// the rate ramps from zero, 100 arrivals over the ramp, and then holds for a further 40
+	assert.InDelta(t, 140, len(arrivals), 10, "arrivals should follow the ramp of the rate")
+}
+
+func Test_profileAt(t *testing.T) {
+	start := time.Now()
+	p := newProfile([]suite.Stage{
+		{Ramp: 10 * time.Second, Duration: 10 * time.Second, Clients: 10},
+		{Duration: 10 * time.Second, Clients: 20},
+		{Ramp: 10 * time.Second, Clients: 0},
+	}, start)
+
+	tests := []struct {
+		name   string
+		at     time.Duration
+		target float64
+		stage  int32
+		ok     bool
+	}{
+		{"start of ramp-up", 0, 0, 1, true},
+		{"half way through ramp-up", 5 * time.Second, 5, 1, true},
+		{"hold", 15 * time.Second, 10, 1, true},
+		{"step", 20 * time.Second, 20, 2, true},
+		{"half way through ramp-down", 35 * time.Second, 10, 3, true},
+		{"complete", 40 * time.Second, 0, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, ok := p.at(start.Add(tt.at), clientTarget)
+			assert.Equal(t, tt.target, target)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.stage, p.stage)
+		})
+	}
+}
+
+func Test_followClientStagesIDs(t *testing.T) {
+	population := &suite.Population{Load: suite.Load{Stages: []suite.Stage{{Duration: 50 * time.Millisecond, Clients: 2}, {Duration: 50 * time.Millisecond}, {Duration: 50 * time.Millisecond, Clients: 2}}}}
+	var ids []int
+	newClient := func(cID int) *action.Client {
+		ids = append(ids, cID)
+		return nil
+	}
+
+	followClientStages(context.Background(), &suite.TestSuite{}, population, newClient, &sync.WaitGroup{}, nil)
+
+	// the clients added after the ramp down do not reuse the ids of the retired clients
+	assert.Equal(t, []int{0, 1, 2, 3}, ids)
+	assert.Equal(t, len(ids), population.ClientIDs())
+}
+
+func Test_runTestSuiteStages(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/stages.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	var buff bytes.Buffer
+	log.SetOutput(&buff)
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	start := time.Now()
+	runTestSuite(ts)
+	elapsed := time.Since(start)
+
+	w.Close()
+	r.Close()
+	os.Stdout = rescueStdout
+
+	assert.True(t, elapsed >= stagesDuration(ts.Stages), "run should last for the load profile")
+	assert.Equal(t, "stages", ts.Outcome.Ended)
+	assert.Contains(t, buff.String(), "3 stage load profile, lasting 400ms")
+	// clean up test files
+	os.RemoveAll("results")
+}
+
+func Test_runTestSuitePopulations(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/populations.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	var buff bytes.Buffer
+	log.SetOutput(&buff)
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	runTestSuite(ts)
+
+	w.Close()
+	r.Close()
+	os.Stdout = rescueStdout
+
+	assert.Equal(t, "populations", ts.Outcome.Ended)
+	assert.Contains(t, buff.String(), " > population monitoring, 3 client(s), 2 iterations per client")
+	assert.Contains(t, buff.String(), " > population provisioning, 1 client(s), running for 200ms")
+	// clean up test files
+	os.RemoveAll("results")
+}
+
+func Test_runTestSuiteRandom(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/random.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	var buff bytes.Buffer
+	log.SetOutput(&buff)
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	runTestSuite(ts)
+
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stdout = rescueStdout
+
+	// 2 clients x 5 iterations x 2 picks, the seed makes the picks reproducible so the same 5 of them are sleeps
+	assert.Equal(t, "iterations", ts.Outcome.Ended)
+	assert.Equal(t, 15, strings.Count(string(out), "E"))
+	// clean up test files
+	os.RemoveAll("results")
+}
+
+func Test_runTestSuitePhases(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/phases.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	var buff bytes.Buffer
+	log.SetOutput(&buff)
+	rescueStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+
+	runTestSuite(ts)
+
+	w.Close()
+	os.Stdout = rescueStdout
+
+	assert.Contains(t, buff.String(), " > Init Block defined, executing 1 init actions sequentially up front")
+	assert.Contains(t, buff.String(), " > Teardown Block defined, executing 1 teardown actions sequentially")
+
+	// the setup and teardown phases are stamped on the archived results
+	dirs, _ := ioutil.ReadDir("results")
+	results, _, err := result.UnarchiveResults(filepath.Join("results", dirs[len(dirs)-1].Name()))
+	if err != nil {
+		t.Fatalf("Problem loading results: %v", err)
+	}
+	phases := map[string]int{}
+	for idx := range results {
+		phases[results[idx].Phase]++
+	}
+	assert.Equal(t, map[string]int{"init": 1, "client-setup": 2, "": 4, "client-teardown": 2, "teardown": 1}, phases)
+	assert.Equal(t, "teardown", results[len(results)-1].Phase)
+	// clean up test files
+	os.RemoveAll("results")
+}
+
+func Test_runTestSuiteNested(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/nested.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	var buff bytes.Buffer
+	log.SetOutput(&buff)
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	runTestSuite(ts)
+
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stdout = rescueStdout
+
+	// 1 get, 3 x (1 edit-config + 2 concurrent gets), 1 get-config for the while loop
+	assert.Equal(t, 11, strings.Count(string(out), "E"))
+	// clean up test files
+	os.RemoveAll("results")
+}
+
+func Test_handleBlockLoopWhile(t *testing.T) {
+	server := netconf.NewTestNetconfServer(t).WithRequestHandler(netconf.EchoRequestHandler).WithRequestHandler(netconf.EchoRequestHandler).WithRequestHandler(netconf.FailingRequestHandler)
+	defer server.Close()
+
+	operation, source := "get-config", "running"
+	ts := &suite.TestSuite{Configs: suite.Configs{{Hostname: "localhost", Port: server.Port(), Username: netconf.TestUserName, Password: netconf.TestPassword, Reuseconnection: true}}}
+	block := &suite.Block{Type: "loop", Count: 10, While: "<running/>", Actions: []suite.Action{{Netconf: &suite.Netconf{Hostname: "localhost", Operation: &operation, Source: &source}}}}
+
+	rescueStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+	resultChannel := make(chan result.NetconfResult, 10)
+	handleBlock(context.Background(), ts, action.NewClient(99, time.Now()), block, resultChannel)
+	w.Close()
+	os.Stdout = rescueStdout
+	close(resultChannel)
+
+	// the echoed replies match the while condition, the failed third request leaves no reply and ends the loop
+	var results []result.NetconfResult
+	for r := range resultChannel {
+		results = append(results, r)
+	}
+	assert.Len(t, results, 3)
+	assert.Equal(t, "", results[1].Err)
+	assert.NotEqual(t, "", results[2].Err)
+}
+
+func Test_handleBlockLoopWhilePipelined(t *testing.T) {
+	server := netconf.NewTestNetconfServer(t).WithRequestHandler(netconf.EchoRequestHandler).WithRequestHandler(netconf.EchoRequestHandler).WithRequestHandler(netconf.FailingRequestHandler)
+	defer server.Close()
+
+	operation, source := "get-config", "running"
+	ts := &suite.TestSuite{Configs: suite.Configs{{Hostname: "localhost", Port: server.Port(), Username: netconf.TestUserName, Password: netconf.TestPassword}}}
+	block := &suite.Block{Type: "loop", Count: 10, While: "<running/>", Actions: []suite.Action{{Netconf: &suite.Netconf{Hostname: "localhost", Operation: &operation, Source: &source, Pipeline: 2}}}}
+
+	rescueStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+	resultChannel := make(chan result.NetconfResult, 10)
+	handleBlock(context.Background(), ts, action.NewClient(98, time.Now()), block, resultChannel)
+	w.Close()
+	os.Stdout = rescueStdout
+	close(resultChannel)
+
+	// each pass waits for the reply to its pipelined request before checking the condition
+	var results []result.NetconfResult
+	for r := range resultChannel {
+		results = append(results, r)
+	}
+	assert.Len(t, results, 3)
+	assert.Equal(t, 2, results[0].Pipeline)
+	assert.NotEqual(t, "", results[2].Err)
+}
+
+func Test_runTestSuiteInterrupt(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/duration.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	ts.Duration = time.Hour
+	var buff bytes.Buffer
+	log.SetOutput(&buff)
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		syscall.Kill(os.Getpid(), syscall.SIGINT) // nolint
+	}()
+	start := time.Now()
+	runTestSuite(ts)
+	elapsed := time.Since(start)
+
+	w.Close()
+	r.Close()
+	os.Stdout = rescueStdout
+
+	assert.True(t, elapsed < 2*time.Second, "run should stop shortly after the interrupt")
+	assert.Equal(t, "interrupt", ts.Outcome.Ended)
+	assert.Contains(t, buff.String(), "Received interrupt, no new actions will be started")
+
+	// the results collected before the interrupt are archived
+	dirs, _ := ioutil.ReadDir("results")
+	results, archived, err := result.UnarchiveResults(filepath.Join("results", dirs[len(dirs)-1].Name()))
+	if err != nil {
+		t.Fatalf("Problem loading results: %v", err)
+	}
+	assert.NotEmpty(t, results)
+	assert.Equal(t, "interrupt", archived.Outcome.Ended)
+	// clean up test files
+	os.RemoveAll("results")
+}
+
+func Test_handleClientTeardown(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/phases.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	population := &ts.GetPopulations()[0]
+	rescueStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+	results := make(chan result.NetconfResult, 2)
+	run, stop := context.WithCancel(context.Background())
+	load := context.WithValue(run, stoppedKey{}, run.Done())
+
+	// a client whose load ended, for e.g. with its duration, runs its teardown
+	ended, end := context.WithCancel(load)
+	end()
+	handleClientTeardown(ended, ts, population, action.NewClient(0, time.Now()), results)
+	// one stopped by an interrupt or abort does not
+	stop()
+	handleClientTeardown(ended, ts, population, action.NewClient(1, time.Now()), results)
+
+	w.Close()
+	os.Stdout = rescueStdout
+	assert.Len(t, results, 1)
+	assert.Equal(t, 0, (<-results).Client)
+}
+
+func Test_handleSignals(t *testing.T) {
+	var buff bytes.Buffer
+	log.SetOutput(&buff)
+	exited := make(chan int, 1)
+	exit = func(code int) { exited <- code }
+	defer func() { exit = os.Exit }()
+
+	signals := make(chan os.Signal, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupted := make(chan struct{})
+	go handleSignals(signals, cancel, interrupted)
+
+	// the first signal cancels the run, the second exits immediately
+	signals <- syscall.SIGTERM
+	<-interrupted
+	assert.Error(t, ctx.Err())
+	assert.Empty(t, exited)
+	signals <- syscall.SIGTERM
+	assert.Equal(t, 1, <-exited)
+	assert.Contains(t, buff.String(), "Received terminated, exiting immediately")
+}
+
+func Test_relayResults(t *testing.T) {
+	actions := make(chan result.NetconfResult)
+	results := make(chan result.NetconfResult, 1)
+	stop, clientsDone, relayed := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		relayResults(actions, results, stop, clientsDone, time.Hour, nil)
+		close(relayed)
+	}()
+
+	actions <- result.NetconfResult{Client: 1}
+	assert.Equal(t, 1, (<-results).Client)
+
+	// once stopped results is closed, the results of actions still in-flight are discarded
+	close(stop)
+	_, ok := <-results
+	assert.False(t, ok)
+	actions <- result.NetconfResult{Client: 2}
+	// until the clients are done
+	close(clientsDone)
+	<-relayed
+
+	// or the grace period ends
+	stop, relayed = make(chan struct{}), make(chan struct{})
+	go func() {
+		relayResults(actions, make(chan result.NetconfResult), stop, make(chan struct{}), 10*time.Millisecond, nil)
+		close(relayed)
+	}()
+	close(stop)
+	<-relayed
+}
+
+func Test_runTestSuiteAbort(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/duration.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	ts.Duration = time.Hour
+	ts.Abort = &suite.Abort{ConnectionFailures: 5}
+	var buff bytes.Buffer
+	log.SetOutput(&buff)
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	start := time.Now()
+	runTestSuite(ts)
+	elapsed := time.Since(start)
+
+	w.Close()
+	r.Close()
+	os.Stdout = rescueStdout
+
+	// the host refuses every connection, so the run is aborted rather than lasting the hour
+	assert.True(t, elapsed < 2*time.Second, "run should stop shortly after the abort")
+	assert.Equal(t, "abort", ts.Outcome.Ended)
+	assert.Equal(t, "6 consecutive connection failures", ts.Outcome.Reason)
+	assert.Contains(t, buff.String(), " > Aborting, 6 consecutive connection failures")
+	// clean up test files
+	os.RemoveAll("results")
+}
+
+func Test_handleBlockParallelism(t *testing.T) {
+	sleep := suite.Action{Sleep: &suite.Sleep{Duration: 50}}
+	block := &suite.Block{Type: "concurrent", Actions: []suite.Action{sleep, sleep, sleep, sleep}}
+	client := action.NewClient(0, time.Now())
+
+	start := time.Now()
+	handleBlock(context.Background(), &suite.TestSuite{}, client, block, nil)
+	assert.True(t, time.Since(start) < 100*time.Millisecond, "without parallelism every action is in flight at once")
+
+	// two at a time takes two rounds
+	block.Parallelism = 2
+	start = time.Now()
+	handleBlock(context.Background(), &suite.TestSuite{}, client, block, nil)
+	elapsed := time.Since(start)
+	assert.True(t, elapsed >= 100*time.Millisecond && elapsed < 150*time.Millisecond, "parallelism should cap the actions in flight")
+}
+
+func Test_handleBlocksPacing(t *testing.T) {
+	var misses int64
+	population := &suite.Population{Load: suite.Load{Iterations: 3, Pacing: 100 * time.Millisecond}}
+	population.Blocks = []suite.Block{{Type: "sequential", Actions: []suite.Action{{Sleep: &suite.Sleep{Duration: 10}}}}}
+	client := action.NewClient(0, time.Now())
+	client.Misses = &misses
+	clientWg := sync.WaitGroup{}
+
+	// each iteration waits out the rest of its interval
+	start := time.Now()
+	clientWg.Add(1)
+	handleBlocks(context.Background(), &suite.TestSuite{}, population, client, &clientWg, nil)
+	elapsed := time.Since(start)
+	assert.True(t, elapsed >= 200*time.Millisecond && elapsed < 300*time.Millisecond, "iterations should start at the pacing interval")
+	assert.Equal(t, int64(0), misses)
+	assert.False(t, client.PacingMiss)
+
+	// iterations that overrun the interval start straight away and are counted
+	population.Blocks[0].Actions[0].Sleep.Duration = 150
+	start = time.Now()
+	clientWg.Add(1)
+	handleBlocks(context.Background(), &suite.TestSuite{}, population, client, &clientWg, nil)
+	elapsed = time.Since(start)
+	assert.True(t, elapsed >= 450*time.Millisecond && elapsed < 550*time.Millisecond, "overrun iterations should not wait")
+	assert.Equal(t, int64(2), misses)
+	assert.True(t, client.PacingMiss, "the last iteration started late")
+}
+
+func Test_handleIterationWarmup(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/phases.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	ts.Warmup = &suite.Warmup{Iterations: 1}
+	population := &ts.GetPopulations()[0]
+	client := action.NewClient(0, time.Now())
+	results := make(chan result.NetconfResult, 2)
+
+	// the host refuses the connection, the results are still marked
+	handleIteration(context.Background(), ts, population, client, results)
+	client.PacingMiss = true
+	handleIteration(context.Background(), ts, population, client, results)
+	first, second := <-results, <-results
+	assert.True(t, first.Warmup, "the first iteration is part of the warm-up")
+	assert.False(t, second.Warmup, "the second iteration is measured")
+	// the results record the iteration they were sent in and whether it started late
+	assert.Equal(t, 1, first.Iteration)
+	assert.False(t, first.PacingMiss)
+	assert.Equal(t, 2, second.Iteration)
+	assert.True(t, second.PacingMiss)
+}
+
+func Test_selectScenario(t *testing.T) {
+	ts, err := suite.NewTestSuite("../suite/testdata/scenarios.yml")
+	if err != nil {
+		t.Fatalf("Problem loading YAML file: %v", err)
+	}
+	// the suite only defines its load in scenarios, so one has to be chosen
+	assert.EqualError(t, selectScenario(ts, ""), "choose one of the scenarios smoke, soak with --scenario")
+	assert.NoError(t, selectScenario(ts, "smoke"))
+	assert.Equal(t, "smoke", ts.Scenario)
+
+	// init and teardown blocks are not a load, a scenario still has to be chosen
+	withInit := &suite.TestSuite{Blocks: []suite.Block{{Type: "init"}, {Type: "teardown"}}, Scenarios: map[string]suite.Scenario{"smoke": {}}}
+	assert.EqualError(t, selectScenario(withInit, ""), "choose one of the scenarios smoke with --scenario")
+
+	var buff bytes.Buffer
+	log.SetOutput(&buff)
+	rescueStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	runTestSuite(ts)
+
+	w.Close()
+	r.Close()
+	os.Stdout = rescueStdout
+
+	assert.Contains(t, buff.String(), " > scenario smoke")
+	assert.NotZero(t, ts.Seed, "the seed chosen for the run should be recorded")
+	assert.Equal(t, "iterations", ts.Outcome.Ended)
+	// clean up test files
+	os.RemoveAll("results")
+}
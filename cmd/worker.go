@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/action"
+	"github.com/damianoneill/nc-hammer/result"
+	"github.com/spf13/cobra"
+)
+
+// tokenEnv is the environment variable the worker token is read from when it is not set by flag, so that it need not
+// appear on the command line
+const tokenEnv = "NC_HAMMER_TOKEN"
+
+var (
+	listenFlag = "127.0.0.1:8300"
+	tokenFlag  = os.Getenv(tokenEnv)
+)
+
+// workerCmd represents the worker command
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "Listen for a share of a Test Suite run from a controller, started with run --workers",
+	Long: `Listen for a share of a Test Suite run from a controller, started with run --workers.
+
+A job carries the whole Test Suite, including the SSH passwords of its hosts, as plaintext and the worker sends
+NETCONF load to whichever hosts the job names. The worker only accepts jobs carrying its token (--token or the
+` + tokenEnv + ` environment variable, given to the controller with run --worker-token), but the connection is not
+encrypted. By default the worker listens on localhost only, listen on other interfaces only within a trusted network
+or through a tunnel, for e.g. ssh -L.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if tokenFlag == "" {
+			log.Fatalf("Problem with worker: a token is required, set --token or %v ", tokenEnv)
+		}
+		listener, err := net.Listen("tcp", listenFlag)
+		if err != nil {
+			log.Fatalf("Problem listening for work: %v ", err)
+		}
+		log.Fatal(serveWorker(listener, tokenFlag))
+	},
+}
+
+// jobTimeout limits how long a worker waits for a connection to send its job
+var jobTimeout = 10 * time.Second
+
+// serveWorker executes the jobs, carrying the token, handed to it by controllers, until the listener is closed. Each
+// connection is handled on its own, so that one that sends nothing cannot hold up the others, but only one job is
+// executed at a time.
+func serveWorker(listener net.Listener, token string) error {
+	log.Printf("Worker listening on %v\n", listener.Addr())
+	busy := make(chan struct{}, 1)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go handleJob(conn, token, busy)
+	}
+}
+
+// handleJob executes a controller's job, streaming the results back to it, a job without the worker's token is refused
+// as is one received while the worker is busy, holding busy, with another job
+func handleJob(conn net.Conn, token string, busy chan struct{}) {
+	// nolint
+	defer conn.Close()
+	enc, dec := json.NewEncoder(conn), json.NewDecoder(conn)
+
+	var m message
+	// nolint
+	conn.SetReadDeadline(time.Now().Add(jobTimeout))
+	if err := dec.Decode(&m); err != nil || m.Job == nil {
+		log.Printf("Problem with job from %v: %v\n", conn.RemoteAddr(), err)
+		return
+	}
+	// the controller may run the suite's init blocks before starting the job, so the start is waited for without a
+	// deadline, the controller has shown its token by then
+	// nolint
+	conn.SetReadDeadline(time.Time{})
+	j := m.Job
+	if token == "" || subtle.ConstantTimeCompare([]byte(j.Token), []byte(token)) != 1 {
+		log.Printf("Job from %v refused, the token does not match\n", conn.RemoteAddr())
+		// nolint
+		enc.Encode(message{Err: "job refused, the token does not match the worker's"})
+		return
+	}
+	select {
+	case busy <- struct{}{}:
+		defer func() { <-busy }()
+	default:
+		log.Printf("Job from %v refused, the worker is busy with another job\n", conn.RemoteAddr())
+		// nolint
+		enc.Encode(message{Err: "job refused, the worker is busy with another job"})
+		return
+	}
+	if j.Workers <= 0 || j.Worker < 0 || j.Worker >= j.Workers || j.Suite == nil {
+		// nolint
+		enc.Encode(message{Err: "job should define a suite and a worker index within the number of workers"})
+		return
+	}
+	// the decoded suite's loop conditions are compiled once, rather than on each pass
+	if err := j.Suite.CompileConditions(); err != nil {
+		// nolint
+		enc.Encode(message{Err: err.Error()})
+		return
+	}
+	action.CreateDiagnosticContext(j.Diag)
+	log.Printf("Job from %v, executing share %d of %d of the clients\n", conn.RemoteAddr(), j.Worker+1, j.Workers)
+	if err := enc.Encode(message{Ready: true}); err != nil {
+		return
+	}
+	if err := dec.Decode(&m); err != nil || !m.Start {
+		return
+	}
+	// the clients are timed from the start of the controller's run, by the offset rather than its clock, which may
+	// differ from the worker's
+	received := time.Now()
+	start := received.Add(-m.Offset)
+
+	// any further message from the controller, or the controller going away, stops the job
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopped := make(chan struct{})
+	go func() {
+		var m message
+		// nolint
+		dec.Decode(&m)
+		cancel()
+		close(stopped)
+	}()
+
+	actionChannel := make(chan result.NetconfResult)
+	resultChannel := make(chan result.NetconfResult)
+	stopRelay := make(chan struct{})
+	clientsDone := make(chan struct{})
+	go relayResults(actionChannel, resultChannel, stopRelay, clientsDone, j.GracePeriod, nil)
+	var misses int64
+	finished := runLoad(ctx, j.Suite, partition{index: j.Worker, of: j.Workers}, start, &misses, actionChannel)
+	go func() {
+		<-finished
+		close(clientsDone)
+	}()
+	go func() {
+		select {
+		case <-finished:
+		case <-stopped:
+			awaitInFlight(finished, j.GracePeriod)
+		}
+		action.CloseAllSessions()
+		close(stopRelay)
+	}()
+
+	for r := range resultChannel {
+		r := r
+		if err := enc.Encode(message{Result: &r}); err != nil {
+			cancel()
+		}
+	}
+	// nolint
+	enc.Encode(message{Done: true, Misses: atomic.LoadInt64(&misses)})
+	log.Printf("\nJob from %v completed in %v\n", conn.RemoteAddr(), time.Since(received))
+}
+
+func init() {
+	RootCmd.AddCommand(workerCmd)
+	workerCmd.Flags().StringVar(&listenFlag, "listen", listenFlag, "Address to listen for work on, for e.g. :8300 for every interface")
+	workerCmd.Flags().StringVar(&tokenFlag, "token", tokenFlag, "The token a controller's jobs must carry, defaults to $"+tokenEnv)
+}
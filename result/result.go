@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/damianoneill/nc-hammer/suite"
@@ -14,14 +15,67 @@ import (
 
 // NetconfResult used to store all data related to a NETCONF requests response
 type NetconfResult struct {
-	Client    int
-	SessionID int
-	MessageID string
-	Hostname  string
-	Operation string
-	When      float64
-	Err       string
-	Latency   float64
+	Client     int
+	SessionID  int
+	MessageID  string
+	Hostname   string
+	Operation  string
+	When       float64
+	Err        string
+	Latency    float64
+	Intended   float64 // when the request was scheduled to start, differs from Started when an iteration starts late
+	Started    float64
+	Stage      int    // the load profile stage the request was sent in, numbered from 1
+	Population string // the population of the client that sent the request
+	Phase      string // the setup or teardown phase the request was sent in, empty when part of the measured load
+	Warmup     bool   // true when the request was sent in the warm-up, analyse leaves it out by default
+	Iteration  int    // the client's iteration the request was sent in, numbered from 1, 0 before the first
+	PacingMiss bool   // true when the iteration started late, as the client's previous iteration overran its pacing
+	Datastore  string // the NMDA datastore of a get-data or edit-data
+	Pipeline   int    // the requests the client kept in flight on the session, 0 when it waited for each reply
+	// true when the request failed as a session could not be established with the host, set where the session is dialed
+	ConnectionFailure bool
+	// subscriptions record a result for the create-subscription, once the subscription ends, and for each
+	// notification received, where the latency is the delay from the notification's eventTime to it being received
+	Stream       string  // the stream subscribed to, empty for a YANG-push subscription
+	Events       int     // create-subscription, the notifications received
+	Dropped      int     // the notifications the session dropped as they were not received in time, for a YANG-push notification those since the previous one
+	InterArrival float64 // notification, the time since the previous notification on the subscription, 0 for the first
+	Event        string  // notification, the name of the notification for e.g. push-update
+	// YANG-push subscriptions record a result for each operation on a subscription and for each notification received
+	Subscription   string  // the client's name for the subscription
+	SubscriptionID string  // the id the host assigned to the subscription
+	Period         float64 // establish-subscription and modify-subscription, the period of a periodic subscription
+}
+
+// IsNotification returns true if the result records a notification received on a subscription, rather than a request
+func (r *NetconfResult) IsNotification() bool {
+	return r.Operation == "notification"
+}
+
+// OperationKey identifies the operation for analysis, the operations on NMDA datastores are keyed by datastore so
+// that for e.g. operational state reads are analysed separately from config reads, and pipelined requests are keyed
+// by their pipeline so that they are analysed separately from those that waited for each reply
+func (r *NetconfResult) OperationKey() string {
+	key := r.Operation
+	if r.Datastore != "" {
+		key += " (" + r.Datastore + ")"
+	}
+	if r.Pipeline > 0 {
+		key += " (pipeline " + strconv.Itoa(r.Pipeline) + ")"
+	}
+	return key
+}
+
+// CorrectedLatency returns the latency corrected for coordinated omission, it includes the time the request spent
+// waiting to start after it was scheduled
+func (r *NetconfResult) CorrectedLatency() float64 {
+	return r.Latency + r.Started - r.Intended
+}
+
+// IsConnectionFailure returns true if the request failed because a session could not be established with the host
+func (r *NetconfResult) IsConnectionFailure() bool {
+	return r.ConnectionFailure
 }
 
 // HandleResults processes results as they occur
@@ -30,7 +84,7 @@ func HandleResults(resultChannel chan NetconfResult, handleResultsFinished chan
 	results := []NetconfResult{}
 	for result := range resultChannel {
 		results = append(results, result)
-		if result.Err == "" {
+		if result.Err == "" && !result.IsNotification() {
 			fmt.Printf(".")
 		}
 	}
@@ -63,3 +63,20 @@ func TestUnarchiveResults(t *testing.T) {
 	assert.Equal(t, actualErr, expectedErr)
 
 }
+
+func TestNetconfResult_CorrectedLatency(t *testing.T) {
+	onTime := result.NetconfResult{Latency: 20, Intended: 100, Started: 100}
+	late := result.NetconfResult{Latency: 20, Intended: 100, Started: 350}
+
+	assert.Equal(t, 20.0, onTime.CorrectedLatency())
+	assert.Equal(t, 270.0, late.CorrectedLatency())
+}
+
+func TestNetconfResult_OperationKey(t *testing.T) {
+	r := result.NetconfResult{Operation: "get-config"}
+	assert.Equal(t, "get-config", r.OperationKey())
+	r = result.NetconfResult{Operation: "get-data", Datastore: "operational"}
+	assert.Equal(t, "get-data (operational)", r.OperationKey())
+	r = result.NetconfResult{Operation: "get", Pipeline: 8}
+	assert.Equal(t, "get (pipeline 8)", r.OperationKey())
+}
@@ -0,0 +1,206 @@
+package suite
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Vars are the variables the templates of a netconf action are expanded with, each time the action is executed. The
+// built-ins are methods, for e.g. {{.Counter "orders"}} or {{.RandInt 1 100}}, the values they render are noted so
+// that they can be included in the diagnostics.
+type Vars struct {
+	Client     int    // the id of the client executing the action
+	Iteration  int    // the client's iteration, numbered from 1, 0 in the init and teardown phases
+	Host       string // the hostname the action is sent to
+	Population string // the population of the client, if any
+
+	counters *Counters
+	random   *rand.Rand
+	rendered []string
+}
+
+// NewVars returns the variables for an execution of an action, the counters are shared by the clients of a run and
+// the random values are drawn from random
+func NewVars(client, iteration int, host, population string, counters *Counters, random *rand.Rand) *Vars {
+	return &Vars{Client: client, Iteration: iteration, Host: host, Population: population, counters: counters, random: random}
+}
+
+// Counter returns the next value of the named counter, counting from 1 across all of the clients
+func (v *Vars) Counter(name string) int64 {
+	value := v.counters.next(name)
+	v.note("Counter %q", value, name)
+	return value
+}
+
+// RandInt returns a random int between min and max inclusive
+func (v *Vars) RandInt(min, max int) (int, error) {
+	if max < min {
+		return 0, errors.New("RandInt max cannot be less than min")
+	}
+	value := min + v.random.Intn(max-min+1)
+	v.note("RandInt %d %d", value, min, max)
+	return value, nil
+}
+
+const randomLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// RandString returns a random string of length letters and digits
+func (v *Vars) RandString(length int) string {
+	b := make([]byte, length)
+	for idx := range b {
+		b[idx] = randomLetters[v.random.Intn(len(randomLetters))]
+	}
+	value := string(b)
+	v.note("RandString %d", value, length)
+	return value
+}
+
+// UUID returns a random (version 4) UUID
+func (v *Vars) UUID() string {
+	b := make([]byte, 16)
+	// nolint
+	v.random.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	value := fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	v.note("UUID", value)
+	return value
+}
+
+// Timestamp returns the current time as a YANG date-and-time, in UTC
+func (v *Vars) Timestamp() string {
+	value := time.Now().UTC().Format(time.RFC3339Nano)
+	v.note("Timestamp", value)
+	return value
+}
+
+// Rendered returns the values the built-ins rendered, in the order they were rendered
+func (v *Vars) Rendered() []string {
+	return v.rendered
+}
+
+func (v *Vars) note(format string, value interface{}, a ...interface{}) {
+	v.rendered = append(v.rendered, fmt.Sprintf(format, a...)+"="+fmt.Sprint(value))
+}
+
+// Counters are the named counters of the templates, shared by the clients of a run
+type Counters struct {
+	lock   sync.Mutex
+	values map[string]int64
+}
+
+// NewCounters returns counters that all start from 1
+func NewCounters() *Counters {
+	return &Counters{values: make(map[string]int64)}
+}
+
+func (c *Counters) next(name string) int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.values[name]++
+	return c.values[name]
+}
+
+var countersLock sync.Mutex // guards the lazy creation of the Test Suite's counters
+
+// Counters returns the template counters shared by the clients of a run of the Test Suite
+func (ts *TestSuite) Counters() *Counters {
+	countersLock.Lock()
+	defer countersLock.Unlock()
+	if ts.counters == nil {
+		ts.counters = NewCounters()
+	}
+	return ts.counters
+}
+
+var (
+	templates     = make(map[string]*template.Template)
+	templatesLock sync.Mutex // guards templates, clients share the parsed templates
+)
+
+// isTemplate returns true if the field contains a template action
+func isTemplate(field string) bool {
+	return strings.Contains(field, "{{")
+}
+
+// expand returns the field with its template expanded with the variables, the template is parsed the first time it
+// is expanded
+func expand(name, field string, vars *Vars) (string, error) {
+	templatesLock.Lock()
+	key := name + ":" + field
+	t, present := templates[key]
+	if !present {
+		var err error
+		t, err = template.New(name).Parse(field)
+		if err != nil {
+			templatesLock.Unlock()
+			return "", err
+		}
+		templates[key] = t
+	}
+	templatesLock.Unlock()
+
+	var b strings.Builder
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// expandPointer expands a field that is optional, leaving it as is when it is not a template
+func expandPointer(name string, field *string, vars *Vars) (*string, error) {
+	if field == nil || !isTemplate(*field) {
+		return field, nil
+	}
+	expanded, err := expand(name, *field, vars)
+	return &expanded, err
+}
+
+// Expand returns the action with the templates in its config, method, filter select and expected expanded with the
+// variables, the action itself is returned when it has none
+func (n *Netconf) Expand(vars *Vars) (*Netconf, error) {
+	expanded := *n
+	var err error
+	if expanded.Config, err = expandPointer("config", n.Config, vars); err != nil {
+		return nil, err
+	}
+	if expanded.Method, err = expandPointer("method", n.Method, vars); err != nil {
+		return nil, err
+	}
+	if n.Filter != nil && isTemplate(n.Filter.Select) {
+		filter := *n.Filter
+		if filter.Select, err = expand("select", n.Filter.Select, vars); err != nil {
+			return nil, err
+		}
+		expanded.Filter = &filter
+	}
+	if expanded.Expected, err = expandPointer("expected", n.Expected, vars); err != nil {
+		return nil, err
+	}
+	if expanded.Config == n.Config && expanded.Method == n.Method && expanded.Filter == n.Filter && expanded.Expected == n.Expected {
+		return n, nil
+	}
+	return &expanded, nil
+}
+
+// validateTemplates checks the templates of the netconf actions by expanding them, so that a template that cannot be
+// parsed or refers to an unknown variable is reported when the Test Suite is loaded
+func validateTemplates(ts *TestSuite) error {
+	vars := NewVars(0, 0, "", "", NewCounters(), rand.New(rand.NewSource(1))) // #nosec
+	for _, block := range ts.allBlocks() {
+		for _, action := range block.Actions {
+			if action.Netconf == nil {
+				continue
+			}
+			if _, err := action.Netconf.Expand(vars); err != nil {
+				return errors.New("netconf: " + err.Error())
+			}
+		}
+	}
+	return nil
+}
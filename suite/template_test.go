@@ -0,0 +1,101 @@
+package suite_test
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/damianoneill/nc-hammer/suite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetconf_Expand(t *testing.T) {
+	operation, config, expected := "edit-config", `<interface><name>ge-{{.Client}}/{{.Iteration}}</name><description>{{.Host}}</description></interface>`, "ge-{{.Client}}"
+	n := &suite.Netconf{Hostname: "10.0.0.1", Operation: &operation, Config: &config, Expected: &expected,
+		Filter: &suite.Filter{Type: "xpath", Select: "/interfaces/interface[name='ge-{{.Client}}']"}}
+	expanded, err := n.Expand(suite.NewVars(3, 7, "10.0.0.1", "", suite.NewCounters(), rand.New(rand.NewSource(1))))
+	assert.NoError(t, err)
+	assert.Equal(t, "<interface><name>ge-3/7</name><description>10.0.0.1</description></interface>", *expanded.Config)
+	assert.Equal(t, "/interfaces/interface[name='ge-3']", expanded.Filter.Select)
+	assert.Equal(t, "ge-3", *expanded.Expected)
+	assert.Equal(t, "edit-config", *expanded.Operation)
+	// the action itself is left as is, for its next execution
+	assert.Equal(t, "ge-{{.Client}}", *n.Expected)
+	assert.Equal(t, "/interfaces/interface[name='ge-{{.Client}}']", n.Filter.Select)
+
+	operation = "get"
+	plain := &suite.Netconf{Hostname: "10.0.0.1", Operation: &operation, Filter: &suite.Filter{Type: "subtree", Select: "<users/>"}}
+	expanded, err = plain.Expand(suite.NewVars(3, 7, "10.0.0.1", "", suite.NewCounters(), rand.New(rand.NewSource(1))))
+	assert.NoError(t, err)
+	assert.True(t, plain == expanded, "an action without templates is not copied")
+}
+
+func TestVars_BuiltIns(t *testing.T) {
+	counters := suite.NewCounters()
+	vars := suite.NewVars(1, 1, "10.0.0.1", "", counters, rand.New(rand.NewSource(42)))
+	// counters are shared by the variables of all of the clients
+	assert.Equal(t, int64(1), vars.Counter("orders"))
+	assert.Equal(t, int64(2), suite.NewVars(2, 1, "10.0.0.1", "", counters, rand.New(rand.NewSource(42))).Counter("orders"))
+	assert.Equal(t, int64(1), vars.Counter("users"))
+
+	for i := 0; i < 100; i++ {
+		n, err := vars.RandInt(5, 7)
+		assert.NoError(t, err)
+		assert.True(t, n >= 5 && n <= 7)
+	}
+	_, err := vars.RandInt(7, 5)
+	assert.EqualError(t, err, "RandInt max cannot be less than min")
+	assert.Regexp(t, regexp.MustCompile(`^[a-zA-Z0-9]{12}$`), vars.RandString(12))
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), vars.UUID())
+	_, err = time.Parse(time.RFC3339Nano, vars.Timestamp())
+	assert.NoError(t, err)
+
+	// the same seed draws the same values
+	seeded := func() string {
+		return suite.NewVars(1, 1, "10.0.0.1", "", counters, rand.New(rand.NewSource(42))).UUID()
+	}
+	assert.Equal(t, seeded(), seeded())
+
+	rendered := suite.NewVars(1, 1, "10.0.0.1", "", suite.NewCounters(), rand.New(rand.NewSource(42)))
+	rendered.Counter("orders")
+	rendered.RandString(4)
+	assert.Len(t, rendered.Rendered(), 2)
+	assert.Equal(t, `Counter "orders"=1`, rendered.Rendered()[0])
+	assert.Regexp(t, regexp.MustCompile(`^RandString 4=[a-zA-Z0-9]{4}$`), rendered.Rendered()[1])
+}
+
+func TestNewTestSuite_TemplateInvalid(t *testing.T) {
+	tests := []struct {
+		netconf string
+		want    string
+	}{
+		{"operation: get\n        expected: ge-{{.Client", `netconf: template: expected:1: unclosed action`},
+		{"operation: get\n        expected: ge-{{.Device}}", `netconf: template: expected:1:5: executing "expected" at <.Device>: can't evaluate field Device in type *suite.Vars`},
+		{"message: rpc\n        method: \"<get-schema><version>{{.RandInt 9 1}}</version></get-schema>\"", `netconf: template: method:1:23: executing "method" at <.RandInt>: error calling RandInt: RandInt max cannot be less than min`},
+	}
+	for _, tt := range tests {
+		_, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions:\n    - netconf:\n        hostname: 10.0.0.1\n        "+tt.netconf)
+		assert.EqualError(t, err, tt.want)
+	}
+}
+
+func TestNewTestSuite_TemplateSnippet(t *testing.T) {
+	f, err := ioutil.TempFile("", "snippet")
+	if err != nil {
+		t.Fatalf("Problem creating temporary file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("<interface>\n  <name>ge-{{.Counter \"interfaces\"}}</name>\n</interface>\n")
+	f.Close()
+
+	ts, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions:\n    - netconf:\n        hostname: 10.0.0.1\n        operation: edit-config\n        target: running\n        config: file:"+f.Name())
+	assert.NoError(t, err)
+	// the template survives the snippet being inlined
+	n := ts.Blocks[0].Actions[0].Netconf
+	expanded, err := n.Expand(suite.NewVars(1, 1, "10.0.0.1", "", ts.Counters(), rand.New(rand.NewSource(1))))
+	assert.NoError(t, err)
+	assert.Equal(t, "<interface><name>ge-1</name></interface>", *expanded.Config)
+}
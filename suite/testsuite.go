@@ -3,9 +3,13 @@ package suite
 import (
 	"errors"
 	"io/ioutil"
-	"log"
+	"math/rand"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/beevik/etree"
 	"github.com/tdewolff/minify"
@@ -20,13 +24,16 @@ type Sshconfig struct {
 	Username        string `json:"username" yaml:"username"`
 	Password        string `json:"password" yaml:"password"`
 	Reuseconnection bool   `json:"reuseconnection" yaml:"reuseconnection"`
+	Maxoutstanding  int    `json:"maxoutstanding,omitempty" yaml:"maxoutstanding,omitempty"` // caps the requests in flight to the host, across all clients
+	Pipeline        int    `json:"pipeline,omitempty" yaml:"pipeline,omitempty"`             // the requests each client keeps in flight on its session to the host
 }
 
 // Filter defines the parameters required to generate a subtree or xpath filter within a NETCONF Request
 type Filter struct {
-	Type   string  `json:"type" yaml:"type"`
-	Ns     *string `json:"ns,omitempty" yaml:"ns,omitempty"`
-	Select string  `json:"select" yaml:"select"`
+	Type       string            `json:"type" yaml:"type"`                                 // subtree or xpath
+	Ns         *string           `json:"ns,omitempty" yaml:"ns,omitempty"`                 // subtree, the default namespace of its elements
+	Select     string            `json:"select" yaml:"select"`                             // the subtree elements or the xpath expression
+	Namespaces map[string]string `json:"namespaces,omitempty" yaml:"namespaces,omitempty"` // prefixes bound on the filter
 }
 
 // Netconf struct contains information required to construct a valid NETCONF Operation.
@@ -41,23 +48,161 @@ type Netconf struct {
 	Filter    *Filter `json:"filter,omitempty" yaml:"filter,omitempty"`
 	Config    *string `json:"config,omitempty" yaml:"config,omitempty"`
 	Expected  *string `json:"expected,omitempty" yaml:"expected,omitempty"`
+	Pipeline  int     `json:"pipeline,omitempty" yaml:"pipeline,omitempty"` // overrides the host's pipeline
+	// commit and cancel-commit
+	Confirmed      bool    `json:"confirmed,omitempty" yaml:"confirmed,omitempty"`
+	ConfirmTimeout int     `json:"confirm-timeout,omitempty" yaml:"confirm-timeout,omitempty"` // seconds
+	Persist        *string `json:"persist,omitempty" yaml:"persist,omitempty"`
+	PersistID      *string `json:"persist-id,omitempty" yaml:"persist-id,omitempty"`
+	// kill-session
+	SessionID int `json:"session-id,omitempty" yaml:"session-id,omitempty"`
+	// get-data and edit-data
+	Datastore        *string  `json:"datastore,omitempty" yaml:"datastore,omitempty"` // an NMDA datastore, for e.g. operational
+	ConfigFilter     *bool    `json:"config-filter,omitempty" yaml:"config-filter,omitempty"`
+	MaxDepth         int      `json:"max-depth,omitempty" yaml:"max-depth,omitempty"`         // unbounded when not set
+	OriginFilter     []string `json:"origin-filter,omitempty" yaml:"origin-filter,omitempty"` // for e.g. intended, learned or system
+	WithOrigin       bool     `json:"with-origin,omitempty" yaml:"with-origin,omitempty"`
+	DefaultOperation *string  `json:"default-operation,omitempty" yaml:"default-operation,omitempty"` // and edit-config
+	// edit-config
+	TestOption  *string `json:"test-option,omitempty" yaml:"test-option,omitempty"`
+	ErrorOption *string `json:"error-option,omitempty" yaml:"error-option,omitempty"`
+	URL         *string `json:"url,omitempty" yaml:"url,omitempty"` // loads the config from the url, in place of config
 }
 
-// Sleep is an action instructing the client to sleep for the period defined in duration
+// Operations are the NETCONF operations that can be defined by name, any other RPC can be sent as a message
+var Operations = []string{"get", "get-config", "edit-config", "copy-config", "delete-config", "lock", "unlock",
+	"close-session", "kill-session", "commit", "discard-changes", "cancel-commit", "validate", "get-data", "edit-data"}
+
+// Datastores are the NMDA datastores that get-data can read from, the first three can be written by edit-data
+var Datastores = []string{"running", "candidate", "startup", "intended", "operational"}
+
+const (
+	nmdaNS       = "urn:ietf:params:xml:ns:yang:ietf-netconf-nmda"
+	datastoresNS = "urn:ietf:params:xml:ns:yang:ietf-datastores"
+	originNS     = "urn:ietf:params:xml:ns:yang:ietf-origin"
+	notifyNS     = "urn:ietf:params:xml:ns:netconf:notification:1.0"
+	subscribedNS = "urn:ietf:params:xml:ns:yang:ietf-subscribed-notifications"
+	yangPushNS   = "urn:ietf:params:xml:ns:yang:ietf-yang-push"
+)
+
+// Sleep is an action instructing the client to sleep, for the period defined in duration or for a period drawn from
+// a distribution. All periods are in milliseconds.
 type Sleep struct {
-	Duration int `json:"duration" yaml:"duration"` // seconds
+	Duration     int    `json:"duration,omitempty" yaml:"duration,omitempty"`         // constant
+	Distribution string `json:"distribution,omitempty" yaml:"distribution,omitempty"` // constant, uniform, exponential or normal, defaults to constant
+	Min          int    `json:"min,omitempty" yaml:"min,omitempty"`                   // uniform
+	Max          int    `json:"max,omitempty" yaml:"max,omitempty"`                   // uniform
+	Mean         int    `json:"mean,omitempty" yaml:"mean,omitempty"`                 // exponential and normal
+	Stddev       int    `json:"stddev,omitempty" yaml:"stddev,omitempty"`             // normal
+	Seed         int64  `json:"seed,omitempty" yaml:"seed,omitempty"`                 // makes the periods drawn reproducible
+}
+
+// Period returns how long to sleep for, drawn from the distribution using r. A normal distribution can draw a
+// negative period, this is treated as no sleep.
+func (s *Sleep) Period(r *rand.Rand) time.Duration {
+	var ms float64
+	switch s.Distribution {
+	case "uniform":
+		ms = float64(s.Min) + r.Float64()*float64(s.Max-s.Min)
+	case "exponential":
+		ms = r.ExpFloat64() * float64(s.Mean)
+	case "normal":
+		ms = r.NormFloat64()*float64(s.Stddev) + float64(s.Mean)
+	default:
+		ms = float64(s.Duration)
+	}
+	if ms < 0 {
+		return 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// Subscribe is an action that creates an RFC 5277 notification subscription, on a session of its own, and holds it
+// open for the duration or until count notifications have been received, whichever comes first
+type Subscribe struct {
+	Hostname  string        `json:"hostname" yaml:"hostname"`
+	Stream    *string       `json:"stream,omitempty" yaml:"stream,omitempty"` // defaults to the NETCONF stream
+	Filter    *Filter       `json:"filter,omitempty" yaml:"filter,omitempty"`
+	StartTime *string       `json:"start-time,omitempty" yaml:"start-time,omitempty"` // replays the notifications logged since, a date-and-time
+	StopTime  *string       `json:"stop-time,omitempty" yaml:"stop-time,omitempty"`   // ends the replay, a date-and-time
+	Duration  time.Duration `json:"duration,omitempty" yaml:"duration,omitempty"`
+	Count     int           `json:"count,omitempty" yaml:"count,omitempty"`
+}
+
+// YangPush is an action on a YANG-push subscription (RFC 8639 and RFC 8641). A client establishes its subscriptions
+// to a host alongside each other, on a session it holds for them until the client ends, and refers to a subscription
+// by its name in later modify-subscription and delete-subscription actions.
+type YangPush struct {
+	Hostname        string        `json:"hostname" yaml:"hostname"`
+	Operation       string        `json:"operation" yaml:"operation"`                     // establish-subscription, modify-subscription or delete-subscription
+	Name            string        `json:"name,omitempty" yaml:"name,omitempty"`           // the client's name for the subscription
+	Datastore       *string       `json:"datastore,omitempty" yaml:"datastore,omitempty"` // defaults to operational
+	Filter          *Filter       `json:"filter,omitempty" yaml:"filter,omitempty"`       // selects the datastore nodes pushed
+	Period          time.Duration `json:"period,omitempty" yaml:"period,omitempty"`       // periodic, a multiple of 10ms
+	OnChange        bool          `json:"on-change,omitempty" yaml:"on-change,omitempty"`
+	DampeningPeriod time.Duration `json:"dampening-period,omitempty" yaml:"dampening-period,omitempty"` // on-change, a multiple of 10ms
+	StopTime        *string       `json:"stop-time,omitempty" yaml:"stop-time,omitempty"`               // a date-and-time
 }
 
-// Action is a wrapper for the different actions types (netconf, sleep)
+// YangPushOperations are the operations of a yang-push action
+var YangPushOperations = []string{"establish-subscription", "modify-subscription", "delete-subscription"}
+
+// Action is a wrapper for the different actions types (netconf, subscribe, yang-push, sleep, or a nested block)
 type Action struct {
-	Netconf *Netconf `json:"netconf,omitempty" yaml:"netconf,omitempty"`
-	Sleep   *Sleep   `json:"sleep,omitempty" yaml:"sleep,omitempty"`
+	Netconf   *Netconf   `json:"netconf,omitempty" yaml:"netconf,omitempty"`
+	Subscribe *Subscribe `json:"subscribe,omitempty" yaml:"subscribe,omitempty"`
+	YangPush  *YangPush  `json:"yang-push,omitempty" yaml:"yang-push,omitempty"`
+	Sleep     *Sleep     `json:"sleep,omitempty" yaml:"sleep,omitempty"`
+	Block     *Block     `json:"block,omitempty" yaml:"block,omitempty"`
+	Weight    int        `json:"weight,omitempty" yaml:"weight,omitempty"` // relative likelihood of being picked in a random block, defaults to 1
 }
 
-// Block describes a list of actions and how these should treated; as an init block, sequentially or concurrently
+// Block describes a list of actions and how these should treated; as an init, teardown, client-setup or
+// client-teardown block, sequentially, concurrently, picked at random according to their weights or repeated in a loop
 type Block struct {
-	Type    string   `json:"type" yaml:"type"`
-	Actions []Action `json:"actions" yaml:"actions"`
+	Type        string   `json:"type" yaml:"type"`
+	Actions     []Action `json:"actions" yaml:"actions"`
+	Picks       int      `json:"picks,omitempty" yaml:"picks,omitempty"`             // random block, number of actions picked per pass, defaults to 1
+	Seed        int64    `json:"seed,omitempty" yaml:"seed,omitempty"`               // random block, makes the picks reproducible
+	Parallelism int      `json:"parallelism,omitempty" yaml:"parallelism,omitempty"` // concurrent block, caps the actions in flight per client
+	Count       int      `json:"count,omitempty" yaml:"count,omitempty"`             // loop block, number of times the actions are repeated
+	While       string   `json:"while,omitempty" yaml:"while,omitempty"`             // loop block, regex the last reply must match to repeat
+
+	while *regexp.Regexp // the while condition, compiled when the suite is loaded
+}
+
+// Repeats returns true if the reply matches the while condition of a loop block, a block without a condition always
+// repeats. A block whose condition was not compiled, for e.g. one built in code, compiles it on each call.
+func (b *Block) Repeats(reply string) bool {
+	if b.while != nil {
+		return b.while.MatchString(reply)
+	}
+	match, err := regexp.MatchString(b.While, reply)
+	return match && err == nil
+}
+
+// weight returns the relative likelihood of the action being picked in a random block
+func (a *Action) weight() int {
+	if a.Weight == 0 {
+		return 1
+	}
+	return a.Weight
+}
+
+// Pick returns an action chosen at random, in proportion to the weights of the actions in the block
+func (b *Block) Pick(r *rand.Rand) Action {
+	var total int
+	for idx := range b.Actions {
+		total += b.Actions[idx].weight()
+	}
+	n := r.Intn(total)
+	for idx := range b.Actions {
+		if n < b.Actions[idx].weight() {
+			return b.Actions[idx]
+		}
+		n -= b.Actions[idx].weight()
+	}
+	return b.Actions[len(b.Actions)-1]
 }
 
 // Configs rebinds the slice of Sshconfig so that methods can be constructed against it
@@ -73,14 +218,89 @@ func (c Configs) IsReuseConnection(hostname string) bool {
 	return false
 }
 
+// Stage defines a step in a load profile, the target number of clients (or rate for open loop suites) is reached by
+// ramping linearly over the ramp period and then held for the duration
+type Stage struct {
+	Name     string        `json:"name,omitempty" yaml:"name,omitempty"`
+	Ramp     time.Duration `json:"ramp,omitempty" yaml:"ramp,omitempty"`
+	Duration time.Duration `json:"duration" yaml:"duration"`
+	Clients  int           `json:"clients,omitempty" yaml:"clients,omitempty"`
+	Rate     string        `json:"rate,omitempty" yaml:"rate,omitempty"`
+}
+
+// Outcome records how a Test Suite run ended, it is populated by the runner and archived with the results
+type Outcome struct {
+	Ended  string `json:"ended" yaml:"ended"`                       // iterations, duration, stages, populations, interrupt or abort
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"` // why the run was aborted
+	// PacingMisses is the number of iterations that overran the pacing interval
+	PacingMisses int64 `json:"pacingmisses,omitempty" yaml:"pacingmisses,omitempty"`
+}
+
+// Abort defines the rules that stop a run early when the SUT is struggling, a rule that is not set is not checked
+type Abort struct {
+	ErrorRate          float64       `json:"error-rate,omitempty" yaml:"error-rate,omitempty"`                   // percentage of requests in error over the window
+	P99Latency         time.Duration `json:"p99-latency,omitempty" yaml:"p99-latency,omitempty"`                 // 99th percentile latency over the window
+	Window             time.Duration `json:"window,omitempty" yaml:"window,omitempty"`                           // rolling window, defaults to 30s
+	MinRequests        int           `json:"min-requests,omitempty" yaml:"min-requests,omitempty"`               // requests needed in the window before it is checked, defaults to 10
+	ConnectionFailures int           `json:"connection-failures,omitempty" yaml:"connection-failures,omitempty"` // more than this many consecutive failures to establish a session
+}
+
+// Warmup defines the start of a run whose results are marked, so that analyse can leave them out of the statistics
+type Warmup struct {
+	Duration   time.Duration `json:"duration,omitempty" yaml:"duration,omitempty"`     // iterations started within this period of the run starting
+	Iterations int           `json:"iterations,omitempty" yaml:"iterations,omitempty"` // the first iterations of each client
+}
+
+// Covers returns true if the iteration of a client, numbered from 0 and started elapsed after the run started, is part
+// of the warm-up
+func (w *Warmup) Covers(iteration int, elapsed time.Duration) bool {
+	if w == nil {
+		return false
+	}
+	return iteration < w.Iterations || elapsed < w.Duration
+}
+
+// Load defines how many clients execute the blocks and for how long
+type Load struct {
+	Iterations int           `json:"iterations" yaml:"iterations"`
+	Clients    int           `json:"clients" yaml:"clients"`
+	Rampup     int           `json:"rampup" yaml:"rampup"`
+	Duration   time.Duration `json:"duration,omitempty" yaml:"duration,omitempty"` // when set, overrides iterations
+	Rate       string        `json:"rate,omitempty" yaml:"rate,omitempty"`         // when set, iterations are started at a constant rate e.g. 200/s
+	Stages     []Stage       `json:"stages,omitempty" yaml:"stages,omitempty"`     // when set, clients (or rate) follow the load profile
+	Pacing     time.Duration `json:"pacing,omitempty" yaml:"pacing,omitempty"`     // when set, each client starts an iteration at this interval
+}
+
+// Population is a named group of clients that execute their own blocks, alongside any other populations in the suite
+type Population struct {
+	Name   string `json:"name" yaml:"name"`
+	Load   `yaml:",inline"`
+	Blocks []Block `json:"blocks" yaml:"blocks"`
+}
+
+// Scenario is a named alternative to the suite's load and blocks, for e.g. smoke, load and soak runs that share the
+// suite's configs
+type Scenario struct {
+	Load        `yaml:",inline"`
+	Blocks      []Block      `json:"blocks" yaml:"blocks"`
+	Populations []Population `json:"populations,omitempty" yaml:"populations,omitempty"`
+}
+
 // TestSuite is the top level struct for the yaml document definition
 type TestSuite struct {
-	File       string  `json:"-" yaml:"-"`
-	Iterations int     `json:"iterations" yaml:"iterations"`
-	Clients    int     `json:"clients" yaml:"clients"`
-	Rampup     int     `json:"rampup" yaml:"rampup"`
-	Configs    Configs `json:"configs" yaml:"configs"`
-	Blocks     []Block `json:"blocks" yaml:"blocks"`
+	File        string `json:"-" yaml:"-"`
+	Load        `yaml:",inline"`
+	Configs     Configs             `json:"configs" yaml:"configs"`
+	Blocks      []Block             `json:"blocks" yaml:"blocks"`
+	Populations []Population        `json:"populations,omitempty" yaml:"populations,omitempty"`
+	Scenarios   map[string]Scenario `json:"scenarios,omitempty" yaml:"scenarios,omitempty"`
+	Scenario    string              `json:"scenario,omitempty" yaml:"scenario,omitempty"` // the scenario that was run
+	Abort       *Abort              `json:"abort,omitempty" yaml:"abort,omitempty"`
+	Warmup      *Warmup             `json:"warmup,omitempty" yaml:"warmup,omitempty"`
+	Seed        int64               `json:"seed,omitempty" yaml:"seed,omitempty"` // seeds each client's random choices, recorded when chosen at random
+	Outcome     *Outcome            `json:"outcome,omitempty" yaml:"outcome,omitempty"`
+
+	counters *Counters
 }
 
 // NewTestSuite returns an TestSuite initialized from a yaml file
@@ -103,6 +323,15 @@ func NewTestSuite(file string) (*TestSuite, error) {
 	if err != nil {
 		return nil, err
 	}
+	// the templates are checked once any embedded xml has been inlined
+	err = validateTemplates(&ts)
+	if err != nil {
+		return nil, err
+	}
+	err = ts.CompileConditions()
+	if err != nil {
+		return nil, err
+	}
 
 	ts.File = file
 	return &ts, err
@@ -117,13 +346,11 @@ func InlineXML(ts *TestSuite) error {
 	m := minify.New()
 	m.AddFunc("text/xml", xml.Minify)
 	var err error
-	for _, block := range ts.Blocks {
+	for _, block := range ts.allBlocks() {
 		for _, action := range block.Actions {
 			switch {
 			case action.Netconf != nil && action.Netconf.Operation != nil:
-				if *action.Netconf.Operation == "edit-config" {
-					err = handleSnippet(action.Netconf.Config, m)
-				}
+				err = handleSnippet(action.Netconf.Config, m)
 			case action.Netconf != nil && action.Netconf.Message != nil:
 				err = handleSnippet(action.Netconf.Method, m)
 			}
@@ -181,6 +408,70 @@ func (n *Netconf) ToXMLString() (string, error) {
 	return doc.WriteToString()
 }
 
+// GetStream returns the stream subscribed to, the NETCONF stream when it is not set
+func (s *Subscribe) GetStream() string {
+	if s.Stream == nil {
+		return "NETCONF"
+	}
+	return *s.Stream
+}
+
+// ToXMLString generates a XML representation of the create-subscription for the Subscribe section of the TestSuite
+func (s *Subscribe) ToXMLString() (string, error) {
+	doc := etree.NewDocument()
+	operation := doc.CreateElement("create-subscription")
+	operation.CreateAttr("xmlns", notifyNS)
+	addTextIfPresent(operation, "stream", s.Stream)
+	if err := addFilterIfPresent(s.Filter, operation); err != nil {
+		return "", err
+	}
+	addTextIfPresent(operation, "startTime", s.StartTime)
+	addTextIfPresent(operation, "stopTime", s.StopTime)
+	return doc.WriteToString()
+}
+
+// ToXMLString generates a XML representation of the YangPush section of the TestSuite, id is the subscription id the
+// host assigned when the subscription was established, which modify-subscription and delete-subscription refer to
+func (p *YangPush) ToXMLString(id string) (string, error) {
+	doc := etree.NewDocument()
+	operation := doc.CreateElement(p.Operation)
+	operation.CreateAttr("xmlns", subscribedNS)
+	if p.Operation != "establish-subscription" {
+		operation.CreateElement("id").SetText(id)
+	}
+	if p.Operation == "delete-subscription" {
+		return doc.WriteToString()
+	}
+	operation.CreateAttr("xmlns:yp", yangPushNS)
+	if p.Filter != nil {
+		datastore := "operational"
+		if p.Datastore != nil {
+			datastore = *p.Datastore
+		}
+		operation.CreateAttr("xmlns:ds", datastoresNS)
+		operation.CreateElement("yp:datastore").SetText("ds:" + datastore)
+		if p.Filter.Type == "xpath" {
+			filter := operation.CreateElement("yp:datastore-xpath-filter")
+			addNamespaces(filter, p.Filter.Namespaces)
+			filter.SetText(p.Filter.Select)
+		} else if err := addSubtree(operation.CreateElement("yp:datastore-subtree-filter"), p.Filter); err != nil {
+			return "", err
+		}
+	}
+	addTextIfPresent(operation, "stop-time", p.StopTime)
+	// the periods are in centiseconds
+	switch {
+	case p.Period > 0:
+		operation.CreateElement("yp:periodic").CreateElement("yp:period").SetText(strconv.FormatInt(int64(p.Period/(10*time.Millisecond)), 10))
+	case p.OnChange || p.DampeningPeriod > 0:
+		onChange := operation.CreateElement("yp:on-change")
+		if p.DampeningPeriod > 0 {
+			onChange.CreateElement("yp:dampening-period").SetText(strconv.FormatInt(int64(p.DampeningPeriod/(10*time.Millisecond)), 10))
+		}
+	}
+	return doc.WriteToString()
+}
+
 func handleMessage(n *Netconf, doc *etree.Document) error {
 	switch {
 	case *n.Message == "rpc":
@@ -200,57 +491,386 @@ func handleOperation(n *Netconf, doc *etree.Document) error {
 	operation := doc.CreateElement(*n.Operation)
 	switch *n.Operation {
 	case "get-config":
-		source := operation.CreateElement("source")
-		if n.Source != nil {
-			source.CreateElement(*n.Source)
-		} else {
-			source.CreateElement("running")
-		}
-		addFilterIfPresent(n, operation)
-		return nil
+		addDatastore(operation, "source", n.Source, "running")
+		return addFilterIfPresent(n.Filter, operation)
 	case "get":
-		addFilterIfPresent(n, operation)
-		return nil
+		return addFilterIfPresent(n.Filter, operation)
 	case "edit-config":
-		source := operation.CreateElement("target")
-		if n.Target != nil {
-			source.CreateElement(*n.Target)
-		} else {
-			source.CreateElement("running")
+		addDatastore(operation, "target", n.Target, "running")
+		addTextIfPresent(operation, "default-operation", n.DefaultOperation)
+		addTextIfPresent(operation, "test-option", n.TestOption)
+		addTextIfPresent(operation, "error-option", n.ErrorOption)
+		if n.URL != nil {
+			operation.CreateElement("url").SetText(*n.URL)
+			return nil
 		}
 		config := operation.CreateElement("config")
-		if n.Config != nil {
-			inner := etree.NewDocument()
-			err := inner.ReadFromString(*n.Config)
-			if err != nil {
-				log.Println("Config data is not valid xml")
+		return addConfigIfPresent(n, config)
+	case "lock", "unlock":
+		addDatastore(operation, "target", n.Target, "running")
+		return nil
+	case "commit":
+		if n.Confirmed {
+			operation.CreateElement("confirmed")
+			if n.ConfirmTimeout > 0 {
+				operation.CreateElement("confirm-timeout").SetText(strconv.Itoa(n.ConfirmTimeout))
 			}
-			config.AddChild(inner.Root().Copy())
+			if n.Persist != nil {
+				operation.CreateElement("persist").SetText(*n.Persist)
+			}
+		}
+		if n.PersistID != nil {
+			operation.CreateElement("persist-id").SetText(*n.PersistID)
+		}
+		return nil
+	case "cancel-commit":
+		if n.PersistID != nil {
+			operation.CreateElement("persist-id").SetText(*n.PersistID)
+		}
+		return nil
+	case "discard-changes", "close-session":
+		return nil
+	case "validate":
+		source := operation.CreateElement("source")
+		if n.Config != nil {
+			return addConfigIfPresent(n, source.CreateElement("config"))
+		}
+		addDatastore(source, "", n.Source, "candidate")
+		return nil
+	case "copy-config":
+		addDatastore(operation, "target", n.Target, "")
+		source := operation.CreateElement("source")
+		if n.Config != nil {
+			return addConfigIfPresent(n, source.CreateElement("config"))
 		}
+		addDatastore(source, "", n.Source, "")
+		return nil
+	case "delete-config":
+		addDatastore(operation, "target", n.Target, "")
+		return nil
+	case "kill-session":
+		operation.CreateElement("session-id").SetText(strconv.Itoa(n.SessionID))
 		return nil
+	case "get-data":
+		addNMDADatastore(operation, n)
+		if err := addDataFilterIfPresent(n, operation); err != nil {
+			return err
+		}
+		if n.ConfigFilter != nil {
+			operation.CreateElement("config-filter").SetText(strconv.FormatBool(*n.ConfigFilter))
+		}
+		if len(n.OriginFilter) > 0 {
+			operation.CreateAttr("xmlns:or", originNS)
+		}
+		for _, origin := range n.OriginFilter {
+			operation.CreateElement("origin-filter").SetText("or:" + origin)
+		}
+		if n.MaxDepth > 0 {
+			operation.CreateElement("max-depth").SetText(strconv.Itoa(n.MaxDepth))
+		}
+		if n.WithOrigin {
+			operation.CreateElement("with-origin")
+		}
+		return nil
+	case "edit-data":
+		addNMDADatastore(operation, n)
+		addTextIfPresent(operation, "default-operation", n.DefaultOperation)
+		return addConfigIfPresent(n, operation.CreateElement("config"))
 	default:
 		return errors.New(*n.Operation + " is not a supported operation")
 	}
 }
 
-func addFilterIfPresent(n *Netconf, operation *etree.Element) {
-	if n.Filter != nil {
-		filter := operation.CreateElement("filter")
-		filter.CreateAttr("type", n.Filter.Type)
-		//  https://github.com/beevik/etree/issues/49
+// addDatastore adds the datastore, or def when it is not set, to the parent, within an element of the given name
+// unless that is empty
+func addDatastore(parent *etree.Element, name string, datastore *string, def string) {
+	if name != "" {
+		parent = parent.CreateElement(name)
+	}
+	if datastore != nil {
+		def = *datastore
+	}
+	parent.CreateElement(def)
+}
+
+// addTextIfPresent adds an element of the given name holding the text, when it is set
+func addTextIfPresent(parent *etree.Element, name string, text *string) {
+	if text != nil {
+		parent.CreateElement(name).SetText(*text)
+	}
+}
+
+// addNMDADatastore declares the NMDA namespaces on the get-data or edit-data operation and adds its datastore
+func addNMDADatastore(operation *etree.Element, n *Netconf) {
+	operation.CreateAttr("xmlns", nmdaNS)
+	operation.CreateAttr("xmlns:ds", datastoresNS)
+	operation.CreateElement("datastore").SetText("ds:" + *n.Datastore)
+}
+
+// addDataFilterIfPresent adds the filter of a get-data, an xpath filter holds the select expression and any other
+// filter is a subtree filter
+func addDataFilterIfPresent(n *Netconf, operation *etree.Element) error {
+	if n.Filter == nil {
+		return nil
+	}
+	if n.Filter.Type == "xpath" {
+		filter := operation.CreateElement("xpath-filter")
+		addNamespaces(filter, n.Filter.Namespaces)
+		filter.SetText(n.Filter.Select)
+		return nil
+	}
+	return addSubtree(operation.CreateElement("subtree-filter"), n.Filter)
+}
+
+// addConfigIfPresent adds the config data to the config element
+func addConfigIfPresent(n *Netconf, config *etree.Element) error {
+	if n.Config != nil {
 		inner := etree.NewDocument()
-		err := inner.ReadFromString(n.Filter.Select)
-		if err != nil {
-			log.Println("Filter Select is not valid xml")
+		err := inner.ReadFromString(*n.Config)
+		if err != nil || inner.Root() == nil {
+			return errors.New("config data is not valid xml")
+		}
+		config.AddChild(inner.Root().Copy())
+	}
+	return nil
+}
+
+func addFilterIfPresent(f *Filter, operation *etree.Element) error {
+	if f != nil {
+		filter := operation.CreateElement("filter")
+		filter.CreateAttr("type", f.Type)
+		// an xpath filter selects with an attribute, any prefixes it uses are bound on the filter
+		if f.Type == "xpath" {
+			addNamespaces(filter, f.Namespaces)
+			filter.CreateAttr("select", f.Select)
+			return nil
+		}
+		return addSubtree(filter, f)
+	}
+	return nil
+}
+
+// addNamespaces declares the prefixes of the namespaces on the element, in prefix order
+func addNamespaces(element *etree.Element, namespaces map[string]string) {
+	var prefixes []string
+	for prefix := range namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		element.CreateAttr("xmlns:"+prefix, namespaces[prefix])
+	}
+}
+
+// addSubtree adds the elements of a subtree filter to the filter element, the select may hold several top level
+// elements. When Ns is set it is the default namespace of the top level elements that do not declare their own.
+func addSubtree(filter *etree.Element, f *Filter) error {
+	addNamespaces(filter, f.Namespaces)
+	//  https://github.com/beevik/etree/issues/49
+	inner := etree.NewDocument()
+	err := inner.ReadFromString("<subtree>" + f.Select + "</subtree>")
+	if err != nil || len(inner.Root().ChildElements()) == 0 {
+		return errors.New("filter select is not valid xml")
+	}
+	for _, element := range inner.Root().ChildElements() {
+		element = element.Copy()
+		if f.Ns != nil && element.SelectAttr("xmlns") == nil {
+			element.CreateAttr("xmlns", *f.Ns)
+		}
+		filter.AddChild(element)
+	}
+	return nil
+}
+
+// IsTimed returns true if the clients loop over the blocks until the run ends, rather than for a number of iterations
+func (l *Load) IsTimed() bool {
+	return l.Duration > 0 || len(l.Stages) > 0
+}
+
+// IsOpenLoop returns true if iterations are started at a rate, rather than by each client on completing its last
+func (l *Load) IsOpenLoop() bool {
+	if l.Rate != "" {
+		return true
+	}
+	for idx := range l.Stages {
+		if l.Stages[idx].Rate != "" {
+			return true
 		}
-		if n.Filter.Ns != nil {
-			top := filter.CreateElement("top")
-			top.CreateAttr("xmlns", *n.Filter.Ns)
-			top.AddChild(inner.Root().Copy())
-		} else {
-			filter.AddChild(inner.Root().Copy())
+	}
+	return false
+}
+
+// MaxClients returns the largest number of clients that the load can have running at once
+func (l *Load) MaxClients() int {
+	if l.IsOpenLoop() || len(l.Stages) == 0 {
+		return l.Clients
+	}
+	var max int
+	for idx := range l.Stages {
+		if l.Stages[idx].Clients > max {
+			max = l.Stages[idx].Clients
 		}
 	}
+	return max
+}
+
+// ClientIDs returns the number of client ids the load uses. A closed loop load profile gives each client it adds a
+// fresh id, as a retired client may still be completing its in-flight actions, so an id is used for every client added
+// over the stages.
+func (l *Load) ClientIDs() int {
+	if l.IsOpenLoop() || len(l.Stages) == 0 {
+		return l.Clients
+	}
+	var ids, previous int
+	for idx := range l.Stages {
+		if l.Stages[idx].Clients > previous {
+			ids += l.Stages[idx].Clients - previous
+		}
+		previous = l.Stages[idx].Clients
+	}
+	return ids
+}
+
+// GetPopulations returns the populations of clients defined in the TestSuite, when none are defined the suite's own
+// load and blocks form a single unnamed population
+func (ts *TestSuite) GetPopulations() []Population {
+	if len(ts.Populations) > 0 {
+		return ts.Populations
+	}
+	return []Population{{Load: ts.Load, Blocks: ts.Blocks}}
+}
+
+// allBlocks returns the blocks of the TestSuite and those of its populations, including any nested blocks
+func (ts *TestSuite) allBlocks() []Block {
+	blocks := nestedBlocks(nil, ts.Blocks)
+	for idx := range ts.Populations {
+		blocks = nestedBlocks(blocks, ts.Populations[idx].Blocks)
+	}
+	for _, name := range ts.ScenarioNames() {
+		scenario := ts.Scenarios[name]
+		blocks = nestedBlocks(blocks, scenario.Blocks)
+		for idx := range scenario.Populations {
+			blocks = nestedBlocks(blocks, scenario.Populations[idx].Blocks)
+		}
+	}
+	return blocks
+}
+
+// ScenarioNames returns the names of the scenarios the suite defines, in order
+func (ts *TestSuite) ScenarioNames() []string {
+	var names []string
+	for name := range ts.Scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SelectScenario replaces the suite's load and blocks with those of the named scenario, the configs, the init and
+// teardown blocks and any other settings are shared. The suite's init blocks run before any of the scenario's and its
+// teardown blocks after. The scenarios are dropped and the name is kept, so that the archived suite records what was
+// run.
+func (ts *TestSuite) SelectScenario(name string) error {
+	scenario, present := ts.Scenarios[name]
+	if !present {
+		return errors.New("scenario " + name + " is not defined, the suite defines " + strings.Join(ts.ScenarioNames(), ", "))
+	}
+	ts.Load = scenario.Load
+	blocks := append(blocksOfType(ts.Blocks, "init"), scenario.Blocks...)
+	ts.Blocks = append(blocks, blocksOfType(ts.Blocks, "teardown")...)
+	ts.Populations = scenario.Populations
+	ts.Scenarios = nil
+	ts.Scenario = name
+	return nil
+}
+
+// HasLoad returns true if the suite defines blocks for its clients to execute, not only init and teardown blocks
+func (ts *TestSuite) HasLoad() bool {
+	if len(ts.Populations) > 0 {
+		return true
+	}
+	for idx := range ts.Blocks {
+		if ts.Blocks[idx].Type != "init" && ts.Blocks[idx].Type != "teardown" {
+			return true
+		}
+	}
+	return false
+}
+
+// CompileConditions compiles the while conditions of the loop blocks, so that they are not compiled on each pass. The
+// conditions of a suite loaded with NewTestSuite are compiled, a suite decoded otherwise, for e.g. by a worker, should
+// be compiled before it is run.
+func (ts *TestSuite) CompileConditions() error {
+	if err := compileConditions(ts.Blocks); err != nil {
+		return err
+	}
+	for idx := range ts.Populations {
+		if err := compileConditions(ts.Populations[idx].Blocks); err != nil {
+			return err
+		}
+	}
+	for _, scenario := range ts.Scenarios {
+		if err := compileConditions(scenario.Blocks); err != nil {
+			return err
+		}
+		for idx := range scenario.Populations {
+			if err := compileConditions(scenario.Populations[idx].Blocks); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compileConditions compiles the while conditions of the blocks in place
+func compileConditions(blocks []Block) error {
+	for idx := range blocks {
+		if err := compileCondition(&blocks[idx]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileCondition compiles the while condition of the block, and those of the blocks nested within its actions
+func compileCondition(block *Block) error {
+	if block.While != "" {
+		var err error
+		if block.while, err = regexp.Compile(block.While); err != nil {
+			return errors.New("loop block: while " + err.Error())
+		}
+	}
+	for _, action := range block.Actions {
+		if action.Block == nil {
+			continue
+		}
+		if err := compileCondition(action.Block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nestedBlocks appends the blocks and the blocks nested within their actions, depth first, to all
+func nestedBlocks(all []Block, blocks []Block) []Block {
+	for idx := range blocks {
+		all = append(all, blocks[idx])
+		for _, action := range blocks[idx].Actions {
+			if action.Block != nil {
+				all = nestedBlocks(all, []Block{*action.Block})
+			}
+		}
+	}
+	return all
+}
+
+// GetPipeline returns the number of requests a client keeps in flight on its session to the host when sending the
+// request, the action's pipeline overrides that of the host. Zero when the client waits for each reply.
+func (n *Netconf) GetPipeline(config *Sshconfig) int {
+	if n.Pipeline > 0 {
+		return n.Pipeline
+	}
+	return config.Pipeline
 }
 
 // GetConfig returns the connection information for a specific host
@@ -263,6 +883,26 @@ func (ts *TestSuite) GetConfig(hostname string) *Sshconfig {
 	return nil
 }
 
+// GetBlocks returns the blocks of the given type, for e.g. init or teardown, in the order they are defined
+func (ts *TestSuite) GetBlocks(blockType string) []Block {
+	return blocksOfType(ts.Blocks, blockType)
+}
+
+// GetBlocks returns the blocks of the given type, for e.g. client-setup or client-teardown, in the order they are defined
+func (p *Population) GetBlocks(blockType string) []Block {
+	return blocksOfType(p.Blocks, blockType)
+}
+
+func blocksOfType(blocks []Block, blockType string) []Block {
+	var matched []Block
+	for idx := range blocks {
+		if blocks[idx].Type == blockType {
+			matched = append(matched, blocks[idx])
+		}
+	}
+	return matched
+}
+
 // GetInitBlock returns an init block if defined in the TestSuite
 func (ts *TestSuite) GetInitBlock() *Block {
 	for _, block := range ts.Blocks {
@@ -277,23 +917,252 @@ func validateTestSuite(ts *TestSuite) error {
 	if len(ts.Configs) == 0 {
 		return errors.New("Testsuite should contain at least one SSH Config section")
 	}
+	if err := validateLoad(&ts.Load); err != nil {
+		return errors.New("Testsuite " + err.Error())
+	}
+	if err := validatePopulations(ts); err != nil {
+		return err
+	}
+	if err := validateScenarios(ts); err != nil {
+		return err
+	}
+	if err := validateAbort(ts.Abort); err != nil {
+		return err
+	}
+	if err := validateWarmup(ts.Warmup); err != nil {
+		return err
+	}
 
 	hosts, err := validateSSHConfig(ts)
 	if err != nil {
 		return err
 	}
 
-	for _, block := range ts.Blocks {
+	for _, block := range ts.allBlocks() {
+		if err = validateBlock(block); err != nil {
+			return err
+		}
 		for _, action := range block.Actions {
 			err = validateNetconfAction(action, hosts)
 			if err != nil {
 				return err
 			}
+			if err = validateSubscribeAction(action, hosts); err != nil {
+				return err
+			}
+			if err = validateYangPushAction(action, hosts); err != nil {
+				return err
+			}
+			if err = validateSleepAction(action); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateLoad(load *Load) error {
+	if load.Duration < 0 {
+		return errors.New("duration cannot be negative")
+	}
+	if load.Rate != "" {
+		if _, err := ParseRate(load.Rate); err != nil {
+			return err
+		}
+	}
+	if load.Pacing < 0 {
+		return errors.New("pacing cannot be negative")
+	}
+	if load.Pacing > 0 && load.IsOpenLoop() {
+		return errors.New("pacing applies to closed loop clients, an open loop rate already paces the iterations")
+	}
+	if err := validateStages(load); err != nil {
+		return err
+	}
+	if load.IsOpenLoop() && load.Clients <= 0 {
+		return errors.New("an open loop rate requires clients to execute the iterations")
+	}
+	return nil
+}
+
+func validatePopulations(ts *TestSuite) error {
+	if len(ts.Populations) == 0 {
+		return nil
+	}
+	for _, block := range ts.Blocks {
+		if block.Type != "init" && block.Type != "teardown" {
+			return errors.New("Testsuite with populations should define its " + block.Type + " blocks within a population")
+		}
+	}
+	var names []string
+	for idx := range ts.Populations {
+		population := &ts.Populations[idx]
+		if population.Name == "" {
+			return errors.New("population: name cannot be empty")
+		}
+		if StringInSlice(population.Name, names) {
+			return errors.New("population: " + population.Name + " is defined more than once")
+		}
+		names = append(names, population.Name)
+		for _, block := range population.Blocks {
+			if block.Type == "init" || block.Type == "teardown" {
+				return errors.New("population: " + population.Name + " should define its " + block.Type + " blocks at the top level")
+			}
+		}
+		if err := validateLoad(&population.Load); err != nil {
+			return errors.New("population: " + population.Name + " " + err.Error())
+		}
+	}
+	return nil
+}
+
+func validateScenarios(ts *TestSuite) error {
+	for _, name := range ts.ScenarioNames() {
+		if name == "" {
+			return errors.New("scenario: name cannot be empty")
+		}
+		// each scenario is checked as the suite it becomes when selected
+		selected := *ts
+		// nolint
+		selected.SelectScenario(name)
+		if err := validateLoad(&selected.Load); err != nil {
+			return errors.New("scenario: " + name + " " + err.Error())
+		}
+		if err := validatePopulations(&selected); err != nil {
+			return errors.New("scenario: " + name + ", " + err.Error())
+		}
+	}
+	return nil
+}
+
+func validateAbort(abort *Abort) error {
+	if abort == nil {
+		return nil
+	}
+	if abort.ErrorRate < 0 || abort.ErrorRate > 100 {
+		return errors.New("abort: error-rate should be a percentage between 0 and 100")
+	}
+	if abort.P99Latency < 0 || abort.Window < 0 || abort.MinRequests < 0 || abort.ConnectionFailures < 0 {
+		return errors.New("abort: p99-latency, window, min-requests and connection-failures cannot be negative")
+	}
+	if abort.ErrorRate == 0 && abort.P99Latency == 0 && abort.ConnectionFailures == 0 {
+		return errors.New("abort: should define at least one of error-rate, p99-latency or connection-failures")
+	}
+	return nil
+}
+
+func validateWarmup(warmup *Warmup) error {
+	if warmup == nil {
+		return nil
+	}
+	if warmup.Duration < 0 || warmup.Iterations < 0 {
+		return errors.New("warmup: duration and iterations cannot be negative")
+	}
+	if (warmup.Duration == 0) == (warmup.Iterations == 0) {
+		return errors.New("warmup: should define one of duration or iterations")
+	}
+	return nil
+}
+
+func validateStages(load *Load) error {
+	openLoop := load.IsOpenLoop()
+	for idx := range load.Stages {
+		stage := load.Stages[idx]
+		if stage.Duration < 0 || stage.Ramp < 0 {
+			return errors.New("stage: duration and ramp cannot be negative")
+		}
+		if stage.Clients < 0 {
+			return errors.New("stage: clients cannot be negative")
+		}
+		if openLoop {
+			if _, err := ParseRate(stage.Rate); err != nil {
+				return errors.New("stage: every stage in an open loop load profile requires a rate, " + err.Error())
+			}
+			if stage.Clients != 0 {
+				return errors.New("stage: an open loop load profile sets a rate per stage, the clients are defined for the suite")
+			}
+		}
+	}
+	if openLoop && len(load.Stages) > 0 && load.Clients <= 0 {
+		return errors.New("an open loop load profile requires clients to execute the iterations")
+	}
+	return nil
+}
+
+func validateBlock(block Block) error {
+	for idx := range block.Actions {
+		if nested := block.Actions[idx].Block; nested != nil && !StringInSlice(nested.Type, []string{"sequential", "concurrent", "random", "loop"}) {
+			return errors.New("block: " + nested.Type + " blocks cannot be nested, nested blocks should be sequential, concurrent, random or loop")
+		}
+	}
+	if block.Parallelism < 0 {
+		return errors.New("block: parallelism cannot be negative")
+	}
+	switch block.Type {
+	case "random":
+		return validateRandomBlock(block)
+	case "loop":
+		return validateLoopBlock(block)
+	}
+	return nil
+}
+
+func validateLoopBlock(block Block) error {
+	if block.Count < 0 {
+		return errors.New("loop block: count cannot be negative")
+	}
+	if block.Count == 0 && block.While == "" {
+		return errors.New("loop block: should define a count, a while condition or both")
+	}
+	if _, err := regexp.Compile(block.While); err != nil {
+		return errors.New("loop block: while " + err.Error())
+	}
+	return nil
+}
+
+func validateRandomBlock(block Block) error {
+	if len(block.Actions) == 0 {
+		return errors.New("random block: should contain at least one action")
+	}
+	if block.Picks < 0 {
+		return errors.New("random block: picks cannot be negative")
+	}
+	for idx := range block.Actions {
+		if block.Actions[idx].Weight < 0 {
+			return errors.New("random block: action weight cannot be negative")
 		}
 	}
 	return nil
 }
 
+func validateSleepAction(action Action) error {
+	if action.Sleep == nil {
+		return nil
+	}
+	sleep := action.Sleep
+	switch sleep.Distribution {
+	case "", "constant":
+		if sleep.Duration < 0 {
+			return errors.New("sleep: duration cannot be negative")
+		}
+	case "uniform":
+		if sleep.Min < 0 || sleep.Max < sleep.Min {
+			return errors.New("sleep: a uniform distribution requires 0 <= min <= max")
+		}
+	case "exponential":
+		if sleep.Mean <= 0 {
+			return errors.New("sleep: an exponential distribution requires a positive mean")
+		}
+	case "normal":
+		if sleep.Mean <= 0 || sleep.Stddev < 0 {
+			return errors.New("sleep: a normal distribution requires a positive mean and a stddev that is not negative")
+		}
+	default:
+		return errors.New("sleep: distribution " + sleep.Distribution + " should be one of constant, uniform, exponential or normal")
+	}
+	return nil
+}
+
 func validateNetconfAction(action Action, hosts []string) error {
 	if action.Netconf != nil {
 		if action.Netconf.Operation == nil && action.Netconf.Message == nil {
@@ -305,6 +1174,221 @@ func validateNetconfAction(action Action, hosts []string) error {
 		if !StringInSlice(action.Netconf.Hostname, hosts) {
 			return errors.New("netconf: action has to use a host defined in the configs section")
 		}
+		if action.Netconf.Pipeline < 0 {
+			return errors.New("netconf: pipeline cannot be negative")
+		}
+		if err := validateFilter(action.Netconf.Filter); err != nil {
+			return errors.New("netconf: " + err.Error())
+		}
+		if action.Netconf.Operation != nil {
+			return validateOperation(action.Netconf)
+		}
+	}
+	return nil
+}
+
+func validateFilter(filter *Filter) error {
+	if filter == nil {
+		return nil
+	}
+	if filter.Type != "subtree" && filter.Type != "xpath" {
+		return errors.New("filter type should be subtree or xpath")
+	}
+	if filter.Type == "xpath" && filter.Ns != nil {
+		return errors.New("filter ns applies to subtree filters, an xpath filter binds its prefixes with namespaces")
+	}
+	return nil
+}
+
+func validateSubscribeAction(action Action, hosts []string) error {
+	subscribe := action.Subscribe
+	if subscribe == nil {
+		return nil
+	}
+	if !StringInSlice(subscribe.Hostname, hosts) {
+		return errors.New("subscribe: action has to use a host defined in the configs section")
+	}
+	if subscribe.Duration < 0 || subscribe.Count < 0 {
+		return errors.New("subscribe: duration and count cannot be negative")
+	}
+	if subscribe.Duration == 0 && subscribe.Count == 0 {
+		return errors.New("subscribe: should define a duration, a count or both, to end the subscription")
+	}
+	if err := validateFilter(subscribe.Filter); err != nil {
+		return errors.New("subscribe: " + err.Error())
+	}
+	for _, t := range []*string{subscribe.StartTime, subscribe.StopTime} {
+		if t == nil {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, *t); err != nil {
+			return errors.New("subscribe: start-time and stop-time should be a date-and-time, for e.g. 2019-01-02T15:04:05Z")
+		}
+	}
+	if subscribe.StopTime != nil && subscribe.StartTime == nil {
+		return errors.New("subscribe: stop-time requires a start-time")
+	}
+	return nil
+}
+
+func validateYangPushAction(action Action, hosts []string) error {
+	p := action.YangPush
+	if p == nil {
+		return nil
+	}
+	if !StringInSlice(p.Hostname, hosts) {
+		return errors.New("yang-push: action has to use a host defined in the configs section")
+	}
+	if !StringInSlice(p.Operation, YangPushOperations) {
+		return errors.New("yang-push: operation should be one of " + strings.Join(YangPushOperations, ", "))
+	}
+	if p.Period < 0 || p.DampeningPeriod < 0 {
+		return errors.New("yang-push: period and dampening-period cannot be negative")
+	}
+	if p.Period%(10*time.Millisecond) != 0 || p.DampeningPeriod%(10*time.Millisecond) != 0 {
+		return errors.New("yang-push: period and dampening-period should be a multiple of 10ms")
+	}
+	if p.Datastore != nil && !StringInSlice(*p.Datastore, Datastores) {
+		return errors.New("yang-push: datastore should be one of " + strings.Join(Datastores, ", "))
+	}
+	if err := validateFilter(p.Filter); err != nil {
+		return errors.New("yang-push: " + err.Error())
+	}
+	if p.StopTime != nil {
+		if _, err := time.Parse(time.RFC3339, *p.StopTime); err != nil {
+			return errors.New("yang-push: stop-time should be a date-and-time, for e.g. 2019-01-02T15:04:05Z")
+		}
+	}
+	if p.Period > 0 && (p.OnChange || p.DampeningPeriod > 0) {
+		return errors.New("yang-push: a subscription is either periodic, with a period, or on-change")
+	}
+	switch p.Operation {
+	case "establish-subscription":
+		if p.Filter == nil {
+			return errors.New("yang-push: establish-subscription requires a filter selecting the datastore nodes")
+		}
+		if p.Period == 0 && !p.OnChange {
+			return errors.New("yang-push: establish-subscription requires a period or on-change")
+		}
+		if p.DampeningPeriod > 0 && !p.OnChange {
+			return errors.New("yang-push: dampening-period requires on-change")
+		}
+	case "modify-subscription":
+		if p.Name == "" {
+			return errors.New("yang-push: modify-subscription requires the name of a subscription the client established")
+		}
+		if p.OnChange {
+			return errors.New("yang-push: modify-subscription cannot change the trigger, on-change only applies to establish-subscription")
+		}
+		if p.Filter == nil && p.Period == 0 && p.DampeningPeriod == 0 && p.StopTime == nil {
+			return errors.New("yang-push: modify-subscription requires a filter, period, dampening-period or stop-time")
+		}
+	case "delete-subscription":
+		if p.Name == "" {
+			return errors.New("yang-push: delete-subscription requires the name of a subscription the client established")
+		}
+		if p.Filter != nil || p.Datastore != nil || p.Period != 0 || p.OnChange || p.DampeningPeriod != 0 || p.StopTime != nil {
+			return errors.New("yang-push: delete-subscription only takes the name of the subscription")
+		}
+	}
+	return nil
+}
+
+func validateOperation(n *Netconf) error {
+	operation := *n.Operation
+	if !StringInSlice(operation, Operations) {
+		return errors.New("netconf: operation " + operation + " should be one of " + strings.Join(Operations, ", ") + ", any other rpc can be sent as a message")
+	}
+	if operation != "commit" && (n.Confirmed || n.ConfirmTimeout != 0 || n.Persist != nil) {
+		return errors.New("netconf: confirmed, confirm-timeout and persist only apply to commit")
+	}
+	if operation != "commit" && operation != "cancel-commit" && n.PersistID != nil {
+		return errors.New("netconf: persist-id only applies to commit and cancel-commit")
+	}
+	switch operation {
+	case "commit":
+		if n.ConfirmTimeout < 0 {
+			return errors.New("netconf: commit confirm-timeout cannot be negative")
+		}
+		if !n.Confirmed && (n.ConfirmTimeout > 0 || n.Persist != nil) {
+			return errors.New("netconf: commit confirm-timeout and persist require confirmed")
+		}
+	case "copy-config":
+		if n.Target == nil || (n.Source == nil) == (n.Config == nil) {
+			return errors.New("netconf: copy-config requires a target and either a source or a config")
+		}
+	case "delete-config":
+		if n.Target == nil || *n.Target == "running" {
+			return errors.New("netconf: delete-config requires a target other than running")
+		}
+	case "validate":
+		if n.Source != nil && n.Config != nil {
+			return errors.New("netconf: validate requires either a source or a config, not both")
+		}
+	case "kill-session":
+		if n.SessionID <= 0 {
+			return errors.New("netconf: kill-session requires a session-id")
+		}
+	}
+	if err := validateEditConfig(n); err != nil {
+		return err
+	}
+	return validateNMDAOperation(n)
+}
+
+func validateEditConfig(n *Netconf) error {
+	if *n.Operation != "edit-config" {
+		if n.TestOption != nil || n.ErrorOption != nil || n.URL != nil {
+			return errors.New("netconf: test-option, error-option and url only apply to edit-config")
+		}
+		return nil
+	}
+	if n.URL != nil && n.Config != nil {
+		return errors.New("netconf: edit-config requires either a config or a url, not both")
+	}
+	if n.TestOption != nil && !StringInSlice(*n.TestOption, []string{"test-then-set", "set", "test-only"}) {
+		return errors.New("netconf: test-option should be one of test-then-set, set or test-only")
+	}
+	if n.ErrorOption != nil && !StringInSlice(*n.ErrorOption, []string{"stop-on-error", "continue-on-error", "rollback-on-error"}) {
+		return errors.New("netconf: error-option should be one of stop-on-error, continue-on-error or rollback-on-error")
+	}
+	return nil
+}
+
+func validateNMDAOperation(n *Netconf) error {
+	operation := *n.Operation
+	if operation != "get-data" && operation != "edit-data" {
+		if n.Datastore != nil {
+			return errors.New("netconf: datastore only applies to get-data and edit-data, other operations use source or target")
+		}
+		if n.DefaultOperation != nil && operation != "edit-config" {
+			return errors.New("netconf: default-operation only applies to edit-config and edit-data")
+		}
+	}
+	if operation != "get-data" && (n.ConfigFilter != nil || n.MaxDepth != 0 || len(n.OriginFilter) > 0 || n.WithOrigin) {
+		return errors.New("netconf: config-filter, max-depth, origin-filter and with-origin only apply to get-data")
+	}
+	switch operation {
+	case "get-data":
+		if n.Datastore == nil || !StringInSlice(*n.Datastore, Datastores) {
+			return errors.New("netconf: get-data requires a datastore, one of " + strings.Join(Datastores, ", "))
+		}
+		if n.MaxDepth < 0 {
+			return errors.New("netconf: get-data max-depth cannot be negative")
+		}
+		if (len(n.OriginFilter) > 0 || n.WithOrigin) && *n.Datastore != "operational" {
+			return errors.New("netconf: get-data origin-filter and with-origin apply to the operational datastore")
+		}
+	case "edit-data":
+		if n.Datastore == nil || !StringInSlice(*n.Datastore, Datastores[:3]) {
+			return errors.New("netconf: edit-data requires a datastore, one of " + strings.Join(Datastores[:3], ", "))
+		}
+		if n.Config == nil {
+			return errors.New("netconf: edit-data requires a config")
+		}
+	}
+	if n.DefaultOperation != nil && !StringInSlice(*n.DefaultOperation, []string{"merge", "replace", "none"}) {
+		return errors.New("netconf: default-operation should be one of merge, replace or none")
 	}
 	return nil
 }
@@ -321,11 +1405,39 @@ func validateSSHConfig(ts *TestSuite) ([]string, error) {
 		if ts.Configs[idx].Password == "" {
 			return nil, errors.New("ssh config: password cannot be empty")
 		}
+		if ts.Configs[idx].Maxoutstanding < 0 {
+			return nil, errors.New("ssh config: maxoutstanding cannot be negative")
+		}
+		if ts.Configs[idx].Pipeline < 0 {
+			return nil, errors.New("ssh config: pipeline cannot be negative")
+		}
 		hosts = append(hosts, ts.Configs[idx].Hostname)
 	}
 	return hosts, nil
 }
 
+// ParseRate converts a rate such as 200/s, 30/m or 100/h to the number of iterations per second
+func ParseRate(rate string) (float64, error) {
+	parts := strings.Split(rate, "/")
+	if len(parts) != 2 {
+		return 0, errors.New("rate: " + rate + " should be of the form count/unit, for e.g. 200/s")
+	}
+	count, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || count <= 0 {
+		return 0, errors.New("rate: " + rate + " should have a positive count")
+	}
+	switch strings.TrimSpace(parts[1]) {
+	case "s":
+		return count, nil
+	case "m":
+		return count / 60, nil
+	case "h":
+		return count / 3600, nil
+	default:
+		return 0, errors.New("rate: " + rate + " unit should be one of s, m or h")
+	}
+}
+
 // StringInSlice helper function to test if a slice contains a value
 func StringInSlice(a string, list []string) bool {
 	for _, b := range list {
@@ -1,8 +1,13 @@
 package suite_test
 
 import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/damianoneill/nc-hammer/cmd"
 	"github.com/damianoneill/nc-hammer/suite"
@@ -78,13 +83,15 @@ func TestNetconf_ToXMLString(t *testing.T) {
 		{"valid get-config", fields{"hostname", nil, nil, cmd.StringAddr("get-config"), nil, nil, nil, nil}, "<get-config><source><running/></source></get-config>", false},
 		{"valid get-config candidate source", fields{"hostname", nil, nil, cmd.StringAddr("get-config"), &candidate, nil, nil, nil}, "<get-config><source><candidate/></source></get-config>", false},
 		{"valid get-config filter", fields{"hostname", nil, nil, cmd.StringAddr("get-config"), nil, nil, &filter, nil}, "<get-config><source><running/></source><filter type=\"type\"><select/></filter></get-config>", false},
-		{"valid get-config filter with ns", fields{"hostname", nil, nil, cmd.StringAddr("get-config"), nil, nil, &filterWithNs, nil}, "<get-config><source><running/></source><filter type=\"type\"><top xmlns=\"urn:ietf:params:xml:ns:netconf:base:1.0\"><select/></top></filter></get-config>", false},
-		{"not supported kill-session", fields{"hostname", nil, nil, cmd.StringAddr("kill-session"), nil, nil, nil, nil}, "", true},
+		{"valid get-config filter with ns", fields{"hostname", nil, nil, cmd.StringAddr("get-config"), nil, nil, &filterWithNs, nil}, "<get-config><source><running/></source><filter type=\"type\"><select xmlns=\"urn:ietf:params:xml:ns:netconf:base:1.0\"/></filter></get-config>", false},
+		{"not supported get-schema", fields{"hostname", nil, nil, cmd.StringAddr("get-schema"), nil, nil, nil, nil}, "", true},
 		{"valid get", fields{"hostname", nil, nil, cmd.StringAddr("get"), nil, nil, nil, nil}, "<get/>", false},
 		{"valid edit-config", fields{"hostname1", nil, nil, cmd.StringAddr("edit-config"), nil, nil, nil, nil}, "<edit-config><target><running/></target><config/></edit-config>", false},
 		{"valid edit-config2", fields{"hostname2", nil, nil, cmd.StringAddr("edit-config"), nil, &candidate, nil, &editOperation}, "<edit-config><target><candidate/></target><config><top xmlns=\"http://example.com/schema/1.2/config\"><interface><name>Ethernet0/0</name><mtu>1500</mtu></interface></top></config></edit-config>", false},
 		{"valid get with filter", fields{"hostname", nil, nil, cmd.StringAddr("get"), nil, nil, &filter, nil}, "<get><filter type=\"type\"><select/></filter></get>", false},
 		{"valid rpc", fields{"hostname", cmd.StringAddr("rpc"), cmd.StringAddr("<some-method><!-- method parameters here... --></some-method>"), nil, nil, nil, nil, nil}, "<some-method><!-- method parameters here... --></some-method>", false},
+		{"invalid edit-config", fields{"hostname", nil, nil, cmd.StringAddr("edit-config"), nil, nil, nil, cmd.StringAddr("<top")}, "", true},
+		{"invalid get filter", fields{"hostname", nil, nil, cmd.StringAddr("get"), nil, nil, &suite.Filter{Type: "subtree", Select: "select"}, nil}, "", true},
 		{"invalid rpc", fields{"hostname", cmd.StringAddr("rpc"), cmd.StringAddr("-- method parameters here... --></some-method>"), nil, nil, nil, nil, nil}, "", true},
 	}
 	for _, tt := range tests {
@@ -111,6 +118,286 @@ func TestNetconf_ToXMLString(t *testing.T) {
 	}
 }
 
+func TestNetconf_ToXMLStringOperations(t *testing.T) {
+	candidate, startup, persist := "candidate", "startup", "id-1"
+	tests := []struct {
+		name    string
+		netconf suite.Netconf
+		want    string
+	}{
+		{"lock", suite.Netconf{Operation: cmd.StringAddr("lock")}, "<lock><target><running/></target></lock>"},
+		{"unlock candidate", suite.Netconf{Operation: cmd.StringAddr("unlock"), Target: &candidate}, "<unlock><target><candidate/></target></unlock>"},
+		{"commit", suite.Netconf{Operation: cmd.StringAddr("commit")}, "<commit/>"},
+		{"confirmed commit", suite.Netconf{Operation: cmd.StringAddr("commit"), Confirmed: true, ConfirmTimeout: 120, Persist: &persist}, "<commit><confirmed/><confirm-timeout>120</confirm-timeout><persist>id-1</persist></commit>"},
+		{"confirming commit", suite.Netconf{Operation: cmd.StringAddr("commit"), PersistID: &persist}, "<commit><persist-id>id-1</persist-id></commit>"},
+		{"cancel-commit", suite.Netconf{Operation: cmd.StringAddr("cancel-commit"), PersistID: &persist}, "<cancel-commit><persist-id>id-1</persist-id></cancel-commit>"},
+		{"discard-changes", suite.Netconf{Operation: cmd.StringAddr("discard-changes")}, "<discard-changes/>"},
+		{"validate", suite.Netconf{Operation: cmd.StringAddr("validate")}, "<validate><source><candidate/></source></validate>"},
+		{"validate config", suite.Netconf{Operation: cmd.StringAddr("validate"), Config: cmd.StringAddr("<top/>")}, "<validate><source><config><top/></config></source></validate>"},
+		{"copy-config", suite.Netconf{Operation: cmd.StringAddr("copy-config"), Target: &startup, Source: cmd.StringAddr("running")}, "<copy-config><target><startup/></target><source><running/></source></copy-config>"},
+		{"copy-config config", suite.Netconf{Operation: cmd.StringAddr("copy-config"), Target: &candidate, Config: cmd.StringAddr("<top/>")}, "<copy-config><target><candidate/></target><source><config><top/></config></source></copy-config>"},
+		{"delete-config", suite.Netconf{Operation: cmd.StringAddr("delete-config"), Target: &startup}, "<delete-config><target><startup/></target></delete-config>"},
+		{"close-session", suite.Netconf{Operation: cmd.StringAddr("close-session")}, "<close-session/>"},
+		{"kill-session", suite.Netconf{Operation: cmd.StringAddr("kill-session"), SessionID: 4}, "<kill-session><session-id>4</session-id></kill-session>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.netconf.ToXMLString()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewTestSuite_OperationInvalid(t *testing.T) {
+	tests := []struct {
+		netconf string
+		want    string
+	}{
+		{"operation: get-schema", "netconf: operation get-schema should be one of get, get-config, edit-config, copy-config, delete-config, lock, unlock, close-session, kill-session, commit, discard-changes, cancel-commit, validate, get-data, edit-data, any other rpc can be sent as a message"},
+		{"operation: lock\n        confirmed: true", "netconf: confirmed, confirm-timeout and persist only apply to commit"},
+		{"operation: validate\n        persist-id: id-1", "netconf: persist-id only applies to commit and cancel-commit"},
+		{"operation: commit\n        confirm-timeout: -1\n        confirmed: true", "netconf: commit confirm-timeout cannot be negative"},
+		{"operation: commit\n        persist: id-1", "netconf: commit confirm-timeout and persist require confirmed"},
+		{"operation: copy-config\n        target: startup", "netconf: copy-config requires a target and either a source or a config"},
+		{"operation: delete-config\n        target: running", "netconf: delete-config requires a target other than running"},
+		{"operation: validate\n        source: candidate\n        config: <top/>", "netconf: validate requires either a source or a config, not both"},
+		{"operation: kill-session", "netconf: kill-session requires a session-id"},
+		{"operation: get\n        pipeline: -1", "netconf: pipeline cannot be negative"},
+	}
+	for _, tt := range tests {
+		_, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions:\n    - netconf:\n        hostname: 10.0.0.1\n        "+tt.netconf)
+		assert.EqualError(t, err, tt.want)
+	}
+}
+
+func TestNetconf_GetPipeline(t *testing.T) {
+	config := &suite.Sshconfig{Hostname: "10.0.0.1", Pipeline: 4}
+	assert.Equal(t, 4, (&suite.Netconf{}).GetPipeline(config), "the host's pipeline")
+	assert.Equal(t, 16, (&suite.Netconf{Pipeline: 16}).GetPipeline(config), "the action's pipeline overrides the host's")
+	assert.Equal(t, 0, (&suite.Netconf{}).GetPipeline(&suite.Sshconfig{}), "the client waits for each reply")
+}
+
+func TestNetconf_ToXMLStringFilters(t *testing.T) {
+	ifNS, sysNS := "urn:ietf:params:xml:ns:yang:ietf-interfaces", "urn:ietf:params:xml:ns:yang:ietf-system"
+	namespaces := map[string]string{"sys": sysNS, "if": ifNS}
+	tests := []struct {
+		name    string
+		netconf suite.Netconf
+		want    string
+	}{
+		{"get xpath", suite.Netconf{Operation: cmd.StringAddr("get"), Filter: &suite.Filter{Type: "xpath", Select: "/if:interfaces/if:interface[if:name='eth0'] | /sys:system", Namespaces: namespaces}},
+			`<get><filter type="xpath" xmlns:if="` + ifNS + `" xmlns:sys="` + sysNS + `" select="/if:interfaces/if:interface[if:name=&apos;eth0&apos;] | /sys:system"/></get>`},
+		{"get subtree with several elements", suite.Netconf{Operation: cmd.StringAddr("get"), Filter: &suite.Filter{Type: "subtree", Select: `<interfaces xmlns="` + ifNS + `"/><system xmlns="` + sysNS + `"/>`}},
+			`<get><filter type="subtree"><interfaces xmlns="` + ifNS + `"/><system xmlns="` + sysNS + `"/></filter></get>`},
+		{"get subtree with default ns", suite.Netconf{Operation: cmd.StringAddr("get"), Filter: &suite.Filter{Type: "subtree", Ns: &ifNS, Select: `<interfaces/><system xmlns="` + sysNS + `"/>`}},
+			`<get><filter type="subtree"><interfaces xmlns="` + ifNS + `"/><system xmlns="` + sysNS + `"/></filter></get>`},
+		{"get subtree with prefixes", suite.Netconf{Operation: cmd.StringAddr("get"), Filter: &suite.Filter{Type: "subtree", Select: "<if:interfaces/>", Namespaces: map[string]string{"if": ifNS}}},
+			`<get><filter type="subtree" xmlns:if="` + ifNS + `"><if:interfaces/></filter></get>`},
+		{"get-data xpath", suite.Netconf{Operation: cmd.StringAddr("get-data"), Datastore: cmd.StringAddr("operational"), Filter: &suite.Filter{Type: "xpath", Select: "/if:interfaces", Namespaces: map[string]string{"if": ifNS}}},
+			`<get-data xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-nmda" xmlns:ds="urn:ietf:params:xml:ns:yang:ietf-datastores"><datastore>ds:operational</datastore><xpath-filter xmlns:if="` + ifNS + `">/if:interfaces</xpath-filter></get-data>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.netconf.ToXMLString()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	invalid := []struct {
+		filter string
+		want   string
+	}{
+		{"type: regex\n          select: x", "netconf: filter type should be subtree or xpath"},
+		{"type: xpath\n          ns: urn:x\n          select: /x", "netconf: filter ns applies to subtree filters, an xpath filter binds its prefixes with namespaces"},
+	}
+	for _, tt := range invalid {
+		_, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions:\n    - netconf:\n        hostname: 10.0.0.1\n        operation: get\n        filter:\n          "+tt.filter)
+		assert.EqualError(t, err, tt.want)
+	}
+}
+
+func TestNetconf_ToXMLStringEditConfig(t *testing.T) {
+	replace, testOnly, rollback, url := "replace", "test-only", "rollback-on-error", "file:///configs/backup.xml"
+	n := suite.Netconf{Operation: cmd.StringAddr("edit-config"), DefaultOperation: &replace, TestOption: &testOnly, ErrorOption: &rollback, Config: cmd.StringAddr("<top/>")}
+	got, err := n.ToXMLString()
+	assert.NoError(t, err)
+	assert.Equal(t, "<edit-config><target><running/></target><default-operation>replace</default-operation><test-option>test-only</test-option><error-option>rollback-on-error</error-option><config><top/></config></edit-config>", got)
+
+	n = suite.Netconf{Operation: cmd.StringAddr("edit-config"), URL: &url}
+	got, err = n.ToXMLString()
+	assert.NoError(t, err)
+	assert.Equal(t, "<edit-config><target><running/></target><url>file:///configs/backup.xml</url></edit-config>", got)
+
+	tests := []struct {
+		netconf string
+		want    string
+	}{
+		{"operation: get\n        error-option: stop-on-error", "netconf: test-option, error-option and url only apply to edit-config"},
+		{"operation: edit-config\n        config: <top/>\n        url: file:///backup.xml", "netconf: edit-config requires either a config or a url, not both"},
+		{"operation: edit-config\n        test-option: test", "netconf: test-option should be one of test-then-set, set or test-only"},
+		{"operation: edit-config\n        error-option: rollback", "netconf: error-option should be one of stop-on-error, continue-on-error or rollback-on-error"},
+		{"operation: edit-config\n        default-operation: delete", "netconf: default-operation should be one of merge, replace or none"},
+	}
+	for _, tt := range tests {
+		_, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions:\n    - netconf:\n        hostname: 10.0.0.1\n        "+tt.netconf)
+		assert.EqualError(t, err, tt.want)
+	}
+}
+
+func TestNetconf_ToXMLStringNMDA(t *testing.T) {
+	operational, running, merge, yes := "operational", "running", "merge", true
+	nmda := `xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-nmda" xmlns:ds="urn:ietf:params:xml:ns:yang:ietf-datastores"`
+	tests := []struct {
+		name    string
+		netconf suite.Netconf
+		want    string
+	}{
+		{"get-data", suite.Netconf{Operation: cmd.StringAddr("get-data"), Datastore: &operational}, "<get-data " + nmda + "><datastore>ds:operational</datastore></get-data>"},
+		{"get-data subtree filter", suite.Netconf{Operation: cmd.StringAddr("get-data"), Datastore: &running, Filter: &suite.Filter{Type: "subtree", Select: "<interfaces/>"}, ConfigFilter: &yes, MaxDepth: 2},
+			"<get-data " + nmda + "><datastore>ds:running</datastore><subtree-filter><interfaces/></subtree-filter><config-filter>true</config-filter><max-depth>2</max-depth></get-data>"},
+		{"get-data xpath filter and origins", suite.Netconf{Operation: cmd.StringAddr("get-data"), Datastore: &operational, Filter: &suite.Filter{Type: "xpath", Select: "/interfaces"}, OriginFilter: []string{"intended", "learned"}, WithOrigin: true},
+			"<get-data " + nmda + ` xmlns:or="urn:ietf:params:xml:ns:yang:ietf-origin"><datastore>ds:operational</datastore><xpath-filter>/interfaces</xpath-filter><origin-filter>or:intended</origin-filter><origin-filter>or:learned</origin-filter><with-origin/></get-data>`},
+		{"edit-data", suite.Netconf{Operation: cmd.StringAddr("edit-data"), Datastore: &running, DefaultOperation: &merge, Config: cmd.StringAddr("<top/>")},
+			"<edit-data " + nmda + "><datastore>ds:running</datastore><default-operation>merge</default-operation><config><top/></config></edit-data>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.netconf.ToXMLString()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewTestSuite_NMDAInvalid(t *testing.T) {
+	tests := []struct {
+		netconf string
+		want    string
+	}{
+		{"operation: get\n        datastore: operational", "netconf: datastore only applies to get-data and edit-data, other operations use source or target"},
+		{"operation: get-config\n        default-operation: merge", "netconf: default-operation only applies to edit-config and edit-data"},
+		{"operation: get\n        with-origin: true", "netconf: config-filter, max-depth, origin-filter and with-origin only apply to get-data"},
+		{"operation: get-data", "netconf: get-data requires a datastore, one of running, candidate, startup, intended, operational"},
+		{"operation: get-data\n        datastore: operational\n        max-depth: -1", "netconf: get-data max-depth cannot be negative"},
+		{"operation: get-data\n        datastore: running\n        with-origin: true", "netconf: get-data origin-filter and with-origin apply to the operational datastore"},
+		{"operation: edit-data\n        datastore: operational", "netconf: edit-data requires a datastore, one of running, candidate, startup"},
+		{"operation: edit-data\n        datastore: running", "netconf: edit-data requires a config"},
+		{"operation: edit-data\n        datastore: running\n        config: <top/>\n        default-operation: delete", "netconf: default-operation should be one of merge, replace or none"},
+	}
+	for _, tt := range tests {
+		_, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions:\n    - netconf:\n        hostname: 10.0.0.1\n        "+tt.netconf)
+		assert.EqualError(t, err, tt.want)
+	}
+}
+
+func TestSubscribe_ToXMLString(t *testing.T) {
+	tests := []struct {
+		name      string
+		subscribe suite.Subscribe
+		want      string
+	}{
+		{"default stream", suite.Subscribe{},
+			`<create-subscription xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"/>`},
+		{"stream with filter and replay", suite.Subscribe{Stream: cmd.StringAddr("syslog"), Filter: &suite.Filter{Type: "subtree", Select: "<event/>"}, StartTime: cmd.StringAddr("2019-01-02T15:04:05Z"), StopTime: cmd.StringAddr("2019-01-02T16:04:05Z")},
+			`<create-subscription xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><stream>syslog</stream><filter type="subtree"><event/></filter><startTime>2019-01-02T15:04:05Z</startTime><stopTime>2019-01-02T16:04:05Z</stopTime></create-subscription>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.subscribe.ToXMLString()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	assert.Equal(t, "NETCONF", (&suite.Subscribe{}).GetStream())
+	assert.Equal(t, "syslog", (&suite.Subscribe{Stream: cmd.StringAddr("syslog")}).GetStream())
+}
+
+func TestNewTestSuite_SubscribeInvalid(t *testing.T) {
+	tests := []struct {
+		subscribe string
+		want      string
+	}{
+		{"hostname: 10.0.0.2\n        count: 1", "subscribe: action has to use a host defined in the configs section"},
+		{"hostname: 10.0.0.1", "subscribe: should define a duration, a count or both, to end the subscription"},
+		{"hostname: 10.0.0.1\n        count: -1", "subscribe: duration and count cannot be negative"},
+		{"hostname: 10.0.0.1\n        count: 1\n        filter:\n          type: regex", "subscribe: filter type should be subtree or xpath"},
+		{"hostname: 10.0.0.1\n        count: 1\n        start-time: yesterday", "subscribe: start-time and stop-time should be a date-and-time, for e.g. 2019-01-02T15:04:05Z"},
+		{"hostname: 10.0.0.1\n        count: 1\n        stop-time: 2019-01-02T15:04:05Z", "subscribe: stop-time requires a start-time"},
+	}
+	for _, tt := range tests {
+		_, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions:\n    - subscribe:\n        "+tt.subscribe)
+		assert.EqualError(t, err, tt.want)
+	}
+
+	ts, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions:\n    - subscribe:\n        hostname: 10.0.0.1\n        stream: syslog\n        duration: 1m\n        start-time: 2019-01-02T15:04:05Z")
+	assert.NoError(t, err)
+	assert.Equal(t, &suite.Subscribe{Hostname: "10.0.0.1", Stream: cmd.StringAddr("syslog"), Duration: time.Minute, StartTime: cmd.StringAddr("2019-01-02T15:04:05Z")}, ts.Blocks[0].Actions[0].Subscribe)
+}
+
+func TestYangPush_ToXMLString(t *testing.T) {
+	sn := `xmlns="urn:ietf:params:xml:ns:yang:ietf-subscribed-notifications"`
+	yp := ` xmlns:yp="urn:ietf:params:xml:ns:yang:ietf-yang-push"`
+	ds := ` xmlns:ds="urn:ietf:params:xml:ns:yang:ietf-datastores"`
+	tests := []struct {
+		name string
+		push suite.YangPush
+		id   string
+		want string
+	}{
+		{"periodic", suite.YangPush{Operation: "establish-subscription", Filter: &suite.Filter{Type: "xpath", Select: "/if:interfaces", Namespaces: map[string]string{"if": "urn:if"}}, Period: 5 * time.Second}, "",
+			`<establish-subscription ` + sn + yp + ds + `><yp:datastore>ds:operational</yp:datastore><yp:datastore-xpath-filter xmlns:if="urn:if">/if:interfaces</yp:datastore-xpath-filter><yp:periodic><yp:period>500</yp:period></yp:periodic></establish-subscription>`},
+		{"on-change", suite.YangPush{Operation: "establish-subscription", Datastore: cmd.StringAddr("running"), Filter: &suite.Filter{Type: "subtree", Select: `<system xmlns="urn:sys"/>`}, OnChange: true, DampeningPeriod: 100 * time.Millisecond, StopTime: cmd.StringAddr("2019-01-02T15:04:05Z")}, "",
+			`<establish-subscription ` + sn + yp + ds + `><yp:datastore>ds:running</yp:datastore><yp:datastore-subtree-filter><system xmlns="urn:sys"/></yp:datastore-subtree-filter><stop-time>2019-01-02T15:04:05Z</stop-time><yp:on-change><yp:dampening-period>10</yp:dampening-period></yp:on-change></establish-subscription>`},
+		{"on-change without dampening", suite.YangPush{Operation: "establish-subscription", OnChange: true}, "",
+			`<establish-subscription ` + sn + yp + `><yp:on-change/></establish-subscription>`},
+		{"modify", suite.YangPush{Operation: "modify-subscription", Name: "stats", Period: time.Second}, "42",
+			`<modify-subscription ` + sn + yp + `><id>42</id><yp:periodic><yp:period>100</yp:period></yp:periodic></modify-subscription>`},
+		{"delete", suite.YangPush{Operation: "delete-subscription", Name: "stats"}, "42",
+			`<delete-subscription ` + sn + `><id>42</id></delete-subscription>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.push.ToXMLString(tt.id)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewTestSuite_YangPushInvalid(t *testing.T) {
+	filter := "\n        filter:\n          type: xpath\n          select: /interfaces"
+	tests := []struct {
+		push string
+		want string
+	}{
+		{"hostname: 10.0.0.2\n        operation: delete-subscription", "yang-push: action has to use a host defined in the configs section"},
+		{"hostname: 10.0.0.1\n        operation: kill-subscription", "yang-push: operation should be one of establish-subscription, modify-subscription, delete-subscription"},
+		{"hostname: 10.0.0.1\n        operation: establish-subscription\n        period: -1s" + filter, "yang-push: period and dampening-period cannot be negative"},
+		{"hostname: 10.0.0.1\n        operation: establish-subscription\n        period: 15ms" + filter, "yang-push: period and dampening-period should be a multiple of 10ms"},
+		{"hostname: 10.0.0.1\n        operation: establish-subscription\n        period: 1s\n        datastore: config" + filter, "yang-push: datastore should be one of running, candidate, startup, intended, operational"},
+		{"hostname: 10.0.0.1\n        operation: establish-subscription\n        period: 1s\n        stop-time: never" + filter, "yang-push: stop-time should be a date-and-time, for e.g. 2019-01-02T15:04:05Z"},
+		{"hostname: 10.0.0.1\n        operation: establish-subscription\n        period: 1s\n        on-change: true" + filter, "yang-push: a subscription is either periodic, with a period, or on-change"},
+		{"hostname: 10.0.0.1\n        operation: establish-subscription\n        period: 1s", "yang-push: establish-subscription requires a filter selecting the datastore nodes"},
+		{"hostname: 10.0.0.1\n        operation: establish-subscription" + filter, "yang-push: establish-subscription requires a period or on-change"},
+		{"hostname: 10.0.0.1\n        operation: establish-subscription\n        dampening-period: 1s" + filter, "yang-push: establish-subscription requires a period or on-change"},
+		{"hostname: 10.0.0.1\n        operation: modify-subscription\n        period: 1s", "yang-push: modify-subscription requires the name of a subscription the client established"},
+		{"hostname: 10.0.0.1\n        operation: modify-subscription\n        name: stats\n        on-change: true", "yang-push: modify-subscription cannot change the trigger, on-change only applies to establish-subscription"},
+		{"hostname: 10.0.0.1\n        operation: modify-subscription\n        name: stats", "yang-push: modify-subscription requires a filter, period, dampening-period or stop-time"},
+		{"hostname: 10.0.0.1\n        operation: delete-subscription", "yang-push: delete-subscription requires the name of a subscription the client established"},
+		{"hostname: 10.0.0.1\n        operation: delete-subscription\n        name: stats\n        period: 1s", "yang-push: delete-subscription only takes the name of the subscription"},
+	}
+	for _, tt := range tests {
+		_, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions:\n    - yang-push:\n        "+tt.push)
+		assert.EqualError(t, err, tt.want)
+	}
+
+	ts, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions:\n    - yang-push:\n        hostname: 10.0.0.1\n        operation: establish-subscription\n        name: stats\n        period: 500ms"+filter)
+	assert.NoError(t, err)
+	assert.Equal(t, &suite.YangPush{Hostname: "10.0.0.1", Operation: "establish-subscription", Name: "stats", Period: 500 * time.Millisecond, Filter: &suite.Filter{Type: "xpath", Select: "/interfaces"}}, ts.Blocks[0].Actions[0].YangPush)
+}
+
 func TestNewTestSuite(t *testing.T) {
 	emptyTs := suite.TestSuite{}
 	emptyTs.File = "testdata/emptytestsuite.yml"
@@ -221,3 +508,390 @@ func TestInlineXML(t *testing.T) {
 	}
 
 }
+
+func TestNewTestSuite_Duration(t *testing.T) {
+	ts, err := suite.NewTestSuite("testdata/duration.yml")
+	if err != nil {
+		t.Fatalf("Problem loading testdata/duration.yml: %v", err)
+	}
+	assert.Equal(t, 300*time.Millisecond, ts.Duration)
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    string
+		want    float64
+		wantErr bool
+	}{
+		{"per second", "200/s", 200, false},
+		{"per minute", "30/m", 0.5, false},
+		{"per hour", "7200/h", 2, false},
+		{"spaces", " 10 / s ", 10, false},
+		{"missing unit", "200", 0, true},
+		{"unknown unit", "200/d", 0, true},
+		{"zero count", "0/s", 0, true},
+		{"invalid count", "abc/s", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := suite.ParseRate(tt.rate)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewTestSuite_Stages(t *testing.T) {
+	ts, err := suite.NewTestSuite("testdata/stages.yml")
+	if err != nil {
+		t.Fatalf("Problem loading testdata/stages.yml: %v", err)
+	}
+	assert.Len(t, ts.Stages, 3)
+	assert.Equal(t, suite.Stage{Name: "ramp-up", Ramp: 100 * time.Millisecond, Duration: 100 * time.Millisecond, Clients: 2}, ts.Stages[0])
+	assert.True(t, ts.IsTimed())
+	assert.False(t, ts.IsOpenLoop())
+}
+
+func TestTestSuite_IsOpenLoop(t *testing.T) {
+	assert.False(t, (&suite.TestSuite{}).IsOpenLoop())
+	assert.True(t, (&suite.TestSuite{Rate: "10/s"}).IsOpenLoop())
+	assert.True(t, (&suite.TestSuite{Stages: []suite.Stage{{Rate: "10/s"}}}).IsOpenLoop())
+}
+
+func TestTestSuite_GetPopulations(t *testing.T) {
+	ts, err := suite.NewTestSuite("testdata/populations.yml")
+	if err != nil {
+		t.Fatalf("Problem loading testdata/populations.yml: %v", err)
+	}
+	populations := ts.GetPopulations()
+	assert.Len(t, populations, 2)
+	assert.Equal(t, "monitoring", populations[0].Name)
+	assert.Equal(t, 3, populations[0].Clients)
+	assert.Equal(t, 200*time.Millisecond, populations[1].Duration)
+	assert.True(t, populations[1].IsTimed())
+
+	// without populations the suite forms a single unnamed population
+	ts, err = suite.NewTestSuite("testdata/testsuite.yml")
+	if err != nil {
+		t.Fatalf("Problem loading testdata/testsuite.yml: %v", err)
+	}
+	populations = ts.GetPopulations()
+	assert.Len(t, populations, 1)
+	assert.Equal(t, "", populations[0].Name)
+	assert.Equal(t, ts.Clients, populations[0].Clients)
+	assert.Equal(t, ts.Blocks, populations[0].Blocks)
+}
+
+func TestLoad_MaxClients(t *testing.T) {
+	assert.Equal(t, 5, (&suite.Load{Clients: 5}).MaxClients())
+	assert.Equal(t, 8, (&suite.Load{Stages: []suite.Stage{{Clients: 8}, {Clients: 2}}}).MaxClients())
+	assert.Equal(t, 5, (&suite.Load{Clients: 5, Stages: []suite.Stage{{Rate: "10/s"}}}).MaxClients())
+}
+
+func TestLoad_ClientIDs(t *testing.T) {
+	assert.Equal(t, 5, (&suite.Load{Clients: 5}).ClientIDs())
+	// the clients added after the ramp down are given fresh ids
+	assert.Equal(t, 11, (&suite.Load{Stages: []suite.Stage{{Clients: 8}, {Clients: 2}, {Clients: 5}}}).ClientIDs())
+	assert.Equal(t, 5, (&suite.Load{Clients: 5, Stages: []suite.Stage{{Rate: "10/s"}}}).ClientIDs())
+}
+
+// newTestSuiteWithBlocks loads a test suite with a single host, 10.0.0.1, and the blocks section given in YAML
+func newTestSuiteWithBlocks(t *testing.T, blocks string) (*suite.TestSuite, error) {
+	t.Helper()
+	f, err := ioutil.TempFile("", "testsuite")
+	if err != nil {
+		t.Fatalf("Problem creating temporary file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	fmt.Fprintf(f, "configs:\n- hostname: 10.0.0.1\n  port: 830\n  username: user\n  password: pass\nblocks:\n%v\n", blocks)
+	f.Close()
+	return suite.NewTestSuite(f.Name())
+}
+
+func TestBlock_Pick(t *testing.T) {
+	ts, err := suite.NewTestSuite("testdata/random.yml")
+	if err != nil {
+		t.Fatalf("Problem loading testdata/random.yml: %v", err)
+	}
+	block := ts.Blocks[0]
+	assert.Equal(t, 2, block.Picks)
+	assert.Equal(t, int64(42), block.Seed)
+
+	// picks are in proportion to the weights, actions without a weight have a weight of 1
+	picks := map[string]int{}
+	r := rand.New(rand.NewSource(block.Seed))
+	for i := 0; i < 5000; i++ {
+		a := block.Pick(r)
+		if a.Sleep != nil {
+			picks["sleep"]++
+		} else {
+			picks[*a.Netconf.Operation]++
+		}
+	}
+	assert.InDelta(t, 3000, picks["get"], 200)
+	assert.InDelta(t, 1000, picks["get-config"], 200)
+	assert.InDelta(t, 1000, picks["sleep"], 200)
+
+	// the same seed repeats the same picks
+	r1, r2 := rand.New(rand.NewSource(block.Seed)), rand.New(rand.NewSource(block.Seed))
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, block.Pick(r1), block.Pick(r2))
+	}
+}
+
+func TestNewTestSuite_RandomInvalid(t *testing.T) {
+	tests := []struct {
+		block string
+		want  string
+	}{
+		{"actions: []", "random block: should contain at least one action"},
+		{"picks: -1\n  actions:\n  - sleep:\n      duration: 1", "random block: picks cannot be negative"},
+		{"actions:\n  - weight: -1\n    sleep:\n      duration: 1", "random block: action weight cannot be negative"},
+	}
+	for _, tt := range tests {
+		_, err := newTestSuiteWithBlocks(t, "- type: random\n  "+tt.block)
+		assert.EqualError(t, err, tt.want)
+	}
+}
+
+func TestTestSuite_GetBlocks(t *testing.T) {
+	ts, err := suite.NewTestSuite("testdata/phases.yml")
+	if err != nil {
+		t.Fatalf("Problem loading testdata/phases.yml: %v", err)
+	}
+	assert.Len(t, ts.GetBlocks("init"), 1)
+	assert.Len(t, ts.GetBlocks("teardown"), 1)
+	population := ts.GetPopulations()[0]
+	assert.Len(t, population.GetBlocks("client-setup"), 1)
+	assert.Equal(t, "edit-config", *population.GetBlocks("client-teardown")[0].Actions[0].Netconf.Operation)
+	assert.Empty(t, population.GetBlocks("random"))
+}
+
+func TestNewTestSuite_Nested(t *testing.T) {
+	ts, err := suite.NewTestSuite("testdata/nested.yml")
+	if err != nil {
+		t.Fatalf("Problem loading testdata/nested.yml: %v", err)
+	}
+	loop := ts.Blocks[0].Actions[1].Block
+	assert.Equal(t, "loop", loop.Type)
+	assert.Equal(t, 3, loop.Count)
+	assert.Equal(t, "concurrent", loop.Actions[1].Block.Type)
+	assert.Len(t, loop.Actions[1].Block.Actions, 2)
+	assert.Equal(t, "<ok/>", ts.Blocks[0].Actions[2].Block.While)
+	// the condition of a nested loop is compiled when the suite is loaded
+	assert.True(t, ts.Blocks[0].Actions[2].Block.Repeats("<rpc-reply><ok/></rpc-reply>"))
+	assert.False(t, ts.Blocks[0].Actions[2].Block.Repeats("<rpc-reply><rpc-error/></rpc-reply>"))
+}
+
+func TestBlock_Repeats(t *testing.T) {
+	// a block built in code compiles its condition when it is checked
+	assert.True(t, (&suite.Block{Type: "loop", While: "<ok/>"}).Repeats("<ok/>"))
+	assert.False(t, (&suite.Block{Type: "loop", While: "<ok/>"}).Repeats(""))
+	assert.False(t, (&suite.Block{Type: "loop", While: "("}).Repeats("("))
+	assert.True(t, (&suite.Block{Type: "loop", Count: 3}).Repeats(""), "a loop without a condition repeats until its count")
+}
+
+func TestNewTestSuite_NestedInvalid(t *testing.T) {
+	tests := []struct {
+		block string
+		want  string
+	}{
+		{"type: loop\n  actions: []", "loop block: should define a count, a while condition or both"},
+		{"type: loop\n  count: -1\n  actions: []", "loop block: count cannot be negative"},
+		{"type: loop\n  while: \"(\"\n  actions: []", "loop block: while error parsing regexp: missing closing ): `(`"},
+		{"type: sequential\n  actions:\n  - block:\n      type: init", "block: init blocks cannot be nested, nested blocks should be sequential, concurrent, random or loop"},
+		{"type: sequential\n  actions:\n  - block:\n      type: loop\n      actions:\n      - netconf:\n          hostname: 10.0.0.2\n          operation: get",
+			"loop block: should define a count, a while condition or both"},
+	}
+	for _, tt := range tests {
+		_, err := newTestSuiteWithBlocks(t, "- "+tt.block)
+		assert.EqualError(t, err, tt.want)
+	}
+}
+
+func TestSleep_Period(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	assert.Equal(t, 50*time.Millisecond, (&suite.Sleep{Duration: 50}).Period(r))
+	assert.Equal(t, 50*time.Millisecond, (&suite.Sleep{Distribution: "constant", Duration: 50}).Period(r))
+
+	sample := func(s suite.Sleep) (min, max, mean time.Duration) {
+		min = time.Hour
+		var total time.Duration
+		for i := 0; i < 10000; i++ {
+			p := s.Period(r)
+			total += p
+			if p < min {
+				min = p
+			}
+			if p > max {
+				max = p
+			}
+		}
+		return min, max, total / 10000
+	}
+	min, max, mean := sample(suite.Sleep{Distribution: "uniform", Min: 100, Max: 200})
+	assert.True(t, min >= 100*time.Millisecond && max < 200*time.Millisecond)
+	assert.InDelta(t, float64(150*time.Millisecond), float64(mean), float64(5*time.Millisecond))
+
+	_, _, mean = sample(suite.Sleep{Distribution: "exponential", Mean: 100})
+	assert.InDelta(t, float64(100*time.Millisecond), float64(mean), float64(5*time.Millisecond))
+
+	_, _, mean = sample(suite.Sleep{Distribution: "normal", Mean: 100, Stddev: 10})
+	assert.InDelta(t, float64(100*time.Millisecond), float64(mean), float64(2*time.Millisecond))
+
+	// a negative period drawn from a normal distribution is treated as no sleep
+	min, _, _ = sample(suite.Sleep{Distribution: "normal", Mean: 1, Stddev: 100})
+	assert.Equal(t, time.Duration(0), min)
+
+	// the same seed draws the same periods
+	s := suite.Sleep{Distribution: "exponential", Mean: 100}
+	r1, r2 := rand.New(rand.NewSource(42)), rand.New(rand.NewSource(42))
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, s.Period(r1), s.Period(r2))
+	}
+}
+
+func TestNewTestSuite_SleepInvalid(t *testing.T) {
+	tests := []struct {
+		sleep string
+		want  string
+	}{
+		{"duration: -1", "sleep: duration cannot be negative"},
+		{"distribution: uniform\n        min: 200\n        max: 100", "sleep: a uniform distribution requires 0 <= min <= max"},
+		{"distribution: exponential", "sleep: an exponential distribution requires a positive mean"},
+		{"distribution: normal\n        mean: 100\n        stddev: -1", "sleep: a normal distribution requires a positive mean and a stddev that is not negative"},
+		{"distribution: poisson", "sleep: distribution poisson should be one of constant, uniform, exponential or normal"},
+	}
+	for _, tt := range tests {
+		_, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions:\n    - sleep:\n        "+tt.sleep)
+		assert.EqualError(t, err, tt.want)
+	}
+	ts, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions:\n    - sleep:\n        distribution: normal\n        mean: 100\n        stddev: 20\n        seed: 7")
+	if err != nil {
+		t.Fatalf("Problem loading sleep distribution: %v", err)
+	}
+	assert.Equal(t, suite.Sleep{Distribution: "normal", Mean: 100, Stddev: 20, Seed: 7}, *ts.Blocks[0].Actions[0].Sleep)
+}
+
+func TestNewTestSuite_Abort(t *testing.T) {
+	ts, err := suite.NewTestSuite("testdata/abort.yml")
+	if err != nil {
+		t.Fatalf("Problem loading testdata/abort.yml: %v", err)
+	}
+	assert.Equal(t, &suite.Abort{ErrorRate: 5, P99Latency: 2 * time.Second, Window: 30 * time.Second, ConnectionFailures: 10}, ts.Abort)
+
+	tests := []struct {
+		abort string
+		want  string
+	}{
+		{"error-rate: 101", "abort: error-rate should be a percentage between 0 and 100"},
+		{"p99-latency: -1s", "abort: p99-latency, window, min-requests and connection-failures cannot be negative"},
+		{"window: 10s", "abort: should define at least one of error-rate, p99-latency or connection-failures"},
+	}
+	for _, tt := range tests {
+		_, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions: []\nabort:\n  "+tt.abort)
+		assert.EqualError(t, err, tt.want)
+	}
+}
+
+func TestNewTestSuite_Pacing(t *testing.T) {
+	ts, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions: []\npacing: 5s")
+	if err != nil {
+		t.Fatalf("Problem loading pacing: %v", err)
+	}
+	assert.Equal(t, 5*time.Second, ts.Pacing)
+
+	_, err = newTestSuiteWithBlocks(t, "- type: sequential\n  actions: []\npacing: -1s")
+	assert.EqualError(t, err, "Testsuite pacing cannot be negative")
+	_, err = newTestSuiteWithBlocks(t, "- type: sequential\n  actions: []\nrate: 10/s\npacing: 1s")
+	assert.EqualError(t, err, "Testsuite pacing applies to closed loop clients, an open loop rate already paces the iterations")
+}
+
+func TestNewTestSuite_OpenLoopClients(t *testing.T) {
+	_, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions: []\nrate: 10/s\nduration: 1h")
+	assert.EqualError(t, err, "Testsuite an open loop rate requires clients to execute the iterations")
+	_, err = newTestSuiteWithBlocks(t, "- type: sequential\n  actions: []\nrate: 10/s\nduration: 1h\nclients: 2")
+	assert.NoError(t, err)
+}
+
+func TestNewTestSuite_Warmup(t *testing.T) {
+	ts, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions: []\nwarmup:\n  duration: 30s")
+	if err != nil {
+		t.Fatalf("Problem loading warmup: %v", err)
+	}
+	assert.True(t, ts.Warmup.Covers(10, 29*time.Second))
+	assert.False(t, ts.Warmup.Covers(0, 30*time.Second))
+
+	ts, err = newTestSuiteWithBlocks(t, "- type: sequential\n  actions: []\nwarmup:\n  iterations: 2")
+	if err != nil {
+		t.Fatalf("Problem loading warmup: %v", err)
+	}
+	assert.True(t, ts.Warmup.Covers(1, time.Hour))
+	assert.False(t, ts.Warmup.Covers(2, 0))
+
+	tests := []struct {
+		warmup string
+		want   string
+	}{
+		{"duration: -1s", "warmup: duration and iterations cannot be negative"},
+		{"duration: 30s\n  iterations: 2", "warmup: should define one of duration or iterations"},
+		{"{}", "warmup: should define one of duration or iterations"},
+	}
+	for _, tt := range tests {
+		_, err := newTestSuiteWithBlocks(t, "- type: sequential\n  actions: []\nwarmup:\n  "+tt.warmup)
+		assert.EqualError(t, err, tt.want)
+	}
+}
+
+func TestTestSuite_SelectScenario(t *testing.T) {
+	ts, err := suite.NewTestSuite("testdata/scenarios.yml")
+	if err != nil {
+		t.Fatalf("Problem loading testdata/scenarios.yml: %v", err)
+	}
+	assert.Equal(t, []string{"smoke", "soak"}, ts.ScenarioNames())
+	assert.EqualError(t, ts.SelectScenario("load"), "scenario load is not defined, the suite defines smoke, soak")
+
+	assert.NoError(t, ts.SelectScenario("soak"))
+	assert.Equal(t, "soak", ts.Scenario)
+	assert.Nil(t, ts.Scenarios)
+	assert.Equal(t, suite.Load{Clients: 5, Duration: 8 * time.Hour, Rampup: 10}, ts.Load)
+	assert.Equal(t, "get-config", *ts.Blocks[0].Actions[0].Netconf.Operation)
+	assert.Equal(t, "00.00.00.00", ts.Configs[0].Hostname)
+}
+
+func TestTestSuite_SelectScenarioInitTeardown(t *testing.T) {
+	ts, err := newTestSuiteWithBlocks(t, "- type: teardown\n  actions: []\n- type: init\n  actions: []\n"+
+		"scenarios:\n  smoke:\n    blocks:\n    - type: init\n      actions: []\n    - type: sequential\n      actions: []")
+	if err != nil {
+		t.Fatalf("Problem loading YAML: %v", err)
+	}
+	assert.False(t, ts.HasLoad(), "the suite defines its load only in its scenarios")
+
+	// the suite's init and teardown blocks are kept, around the scenario's blocks
+	assert.NoError(t, ts.SelectScenario("smoke"))
+	var types []string
+	for _, block := range ts.Blocks {
+		types = append(types, block.Type)
+	}
+	assert.Equal(t, []string{"init", "init", "sequential", "teardown"}, types)
+	assert.True(t, ts.HasLoad())
+}
+
+func TestNewTestSuite_ScenarioInvalid(t *testing.T) {
+	tests := []struct {
+		scenarios string
+		want      string
+	}{
+		{"soak:\n    duration: -1s\n    blocks: []", "scenario: soak duration cannot be negative"},
+		{"soak:\n    blocks:\n    - type: loop\n      actions: []", "loop block: should define a count, a while condition or both"},
+		{"soak:\n    blocks:\n    - type: sequential\n      actions: []\n    populations:\n    - name: monitoring\n      clients: 1\n      blocks: []", "scenario: soak, Testsuite with populations should define its sequential blocks within a population"},
+	}
+	for _, tt := range tests {
+		_, err := newTestSuiteWithBlocks(t, "  []\nscenarios:\n  "+tt.scenarios)
+		assert.EqualError(t, err, tt.want)
+	}
+}